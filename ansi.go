@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Strips ANSI escape sequences out of a line. The styles set by any SGR
+// (select graphic rendition) sequences are returned, one per byte of the
+// remaining text. Other CSI sequences are dropped without effect.
+func parseSGR(data string) (string, []Style) {
+	if strings.IndexByte(data, '\x1b') == -1 {
+		return data, make([]Style, len(data))
+	}
+
+	var text []byte
+	var styles []Style
+	var current Style
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\x1b' || i+1 >= len(data) || data[i+1] != '[' {
+			text = append(text, data[i])
+			styles = append(styles, current)
+			continue
+		}
+
+		// Find the final byte of the CSI sequence.
+		end := i + 2
+		for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+			end++
+		}
+		if end == len(data) {
+			break // Incomplete sequence.
+		}
+		if data[end] == 'm' {
+			current = applySGR(current, data[i+2:end])
+		}
+		i = end
+	}
+	return string(text), styles
+}
+
+func applySGR(style Style, params string) Style {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil && codes[i] != "" {
+			continue
+		}
+		switch {
+		case code == 0:
+			style = Style{}
+		case code >= 30 && code <= 37:
+			style.fg = IndexedColour(uint8(code - 30))
+		case code == 39:
+			style.fg = Default
+		case code >= 40 && code <= 47:
+			style.bg = IndexedColour(uint8(code - 40))
+		case code == 49:
+			style.bg = Default
+		case code >= 90 && code <= 97:
+			style.fg = IndexedColour(uint8(code - 90 + 8))
+		case code >= 100 && code <= 107:
+			style.bg = IndexedColour(uint8(code - 100 + 8))
+		case code == 38 || code == 48:
+			colour, n := parseExtendedColour(codes[i+1:])
+			i += n
+			if code == 38 {
+				style.fg = colour
+			} else {
+				style.bg = colour
+			}
+		default:
+			for _, ac := range attrCodes {
+				if code == ac.on {
+					style.attrs |= ac.attr
+				} else if code == ac.off {
+					style.attrs &^= ac.attr
+				}
+			}
+		}
+	}
+	return style
+}
+
+// Parses the parameters following 38 or 48 (5;n for an indexed colour, or
+// 2;r;g;b for an RGB colour). The number of parameters consumed is returned.
+func parseExtendedColour(params []string) (Colour, int) {
+	nums := make([]uint8, 0, 4)
+	for _, p := range params {
+		n, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			break
+		}
+		nums = append(nums, uint8(n))
+	}
+	switch {
+	case len(nums) >= 2 && nums[0] == 5:
+		return IndexedColour(nums[1]), 2
+	case len(nums) >= 4 && nums[0] == 2:
+		return RGBColour(nums[1], nums[2], nums[3]), 4
+	}
+	return Default, len(nums)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSGR(t *testing.T) {
+	red := MixStyle(Red, Default)
+	redOnBlue := MixStyle(Red, Blue)
+	boldBrightRed := MixStyle(IndexedColour(9), Default).with(Bold)
+	extended := MixStyle(IndexedColour(196), RGBColour(1, 2, 3))
+	for i, test := range []struct {
+		in     string
+		text   string
+		styles []Style
+	}{
+		{"", "", []Style{}},
+		{"ab", "ab", []Style{{}, {}}},
+		{"\x1b[31mab\x1b[0mc", "abc", []Style{red, red, {}}},
+		{"\x1b[31;44ma\x1b[mb", "ab", []Style{redOnBlue, {}}},
+		{"\x1b[1;91ma\x1b[22;39mb", "ab", []Style{boldBrightRed, {}}},
+		{"\x1b[38;5;196;48;2;1;2;3ma\x1b[2Kb", "ab", []Style{extended, extended}},
+		{"a\x1b[3", "a", []Style{{}}},
+		{"a\x1bb", "a\x1bb", []Style{{}, {}, {}}},
+	} {
+		text, styles := parseSGR(test.in)
+		if text != test.text || !reflect.DeepEqual(styles, test.styles) {
+			t.Errorf("%d: in=%q got=(%q, %v) want=(%q, %v)", i, test.in, text, styles, test.text, test.styles)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"regexp"
 	"time"
 )
@@ -12,29 +11,171 @@ type App interface {
 	KeyPress(Key)
 	Interrupt()
 	TermSize(rows, cols int, forceRefresh bool)
-	FileSize(int)
+	FileSize(buffer int, size int)
+	ContentReset(buffer int, reason string)
+	SaveState() error
 }
 
 type app struct {
-	reactor             Reactor
-	screen              Screen
-	fillingScreenBuffer bool
-	forceRefresh        bool
-	model               Model
-	msgSetAt            time.Time
+	reactor      Reactor
+	screen       Screen
+	forceRefresh bool
+	msgSetAt     time.Time
+
+	// Each file being viewed has its own buffer. The model is the buffer
+	// currently being displayed.
+	buffers   []*Model
+	bufferIdx int
+	model     *Model
+
+	store   *StateStore // Nil if state isn't persisted.
+	history map[CommandMode][]string
 }
 
-func NewApp(reactor Reactor, content Content, filename string, screen Screen, config Config) App {
-	return &app{
+func NewApp(reactor Reactor, contents []Content, filenames []string, screen Screen, config Config, store *StateStore) App {
+	assert(len(contents) == len(filenames))
+	assert(len(contents) > 0)
+	a := &app{
 		reactor: reactor,
 		screen:  screen,
-		model: Model{
-			config:   config,
-			content:  content,
-			filename: filename,
-			history:  map[CommandMode][]string{},
-		},
+		store:   store,
+		history: map[CommandMode][]string{}, // Shared between buffers.
 	}
+	if store != nil {
+		history, err := store.LoadHistory()
+		if err != nil {
+			log.Warn("Could not load command history: %v", err)
+		}
+		a.history = history
+	}
+	for i := range contents {
+		m := &Model{
+			config:       config,
+			content:      contents[i],
+			filename:     filenames[i],
+			history:      a.history,
+			following:    config.Follow,
+			ansiColours:  config.AnsiColours,
+			lineWrapMode: config.WrapMode,
+			regexes:      append([]regex(nil), config.Highlights...),
+		}
+		m.resetIndex()
+		m.discardLoaded()
+		a.restoreState(m)
+		a.buffers = append(a.buffers, m)
+	}
+	a.model = a.buffers[0]
+	return a
+}
+
+// Persisted state is only kept for named files (not stdin).
+func (a *app) persisted(m *Model) bool {
+	_, isBuffer := m.content.(*BufferContent)
+	_, isMerged := m.content.(*MergedContent)
+	return a.store != nil && !isBuffer && !isMerged
+}
+
+func (a *app) restoreState(m *Model) {
+	if !a.persisted(m) {
+		return
+	}
+	state, ok, err := a.store.LoadFile(m.filename)
+	if err != nil {
+		log.Warn("Could not load state: filename=%q err=%v", m.filename, err)
+	}
+	if !ok {
+		return
+	}
+	log.Info("Restoring state: filename=%q offset=%d", m.filename, state.Offset)
+
+	// Restored regexes take priority, followed by any new preloaded ones.
+	regexes := restoreRegexes(state.Regexes)
+	for _, pre := range m.regexes {
+		found := false
+		for _, r := range regexes {
+			found = found || r.re.String() == pre.re.String()
+		}
+		if !found {
+			regexes = append(regexes, pre)
+		}
+	}
+	m.regexes = regexes
+	if !m.config.WrapGiven {
+		m.lineWrapMode = state.WrapMode
+	}
+	m.prettyJSON = state.PrettyJSON
+	m.xPosition = state.XPosition
+
+	// The file may have changed since the state was saved, so make sure the
+	// offset is still the start of a line. Content that's still being
+	// decompressed may not have reached the offset yet, in which case it's
+	// restored once it does.
+	size, err := m.content.Size()
+	if err != nil || state.Offset == 0 {
+		return
+	}
+	if int64(state.Offset) >= size {
+		if _, ok := m.content.(*DecompressedContent); ok {
+			m.pendingOffset = state.Offset
+		}
+		return
+	}
+	offset, err := FindReloadOffset(m.content, state.Offset)
+	if err != nil {
+		log.Warn("Could not restore offset: %v", err)
+		return
+	}
+	m.offset = offset
+}
+
+// Restores a pending offset once the content has grown past it. It's given up
+// on if the view has been moved in the meantime.
+func (a *app) restorePendingOffset(m *Model) {
+	if m.pendingOffset == 0 {
+		return
+	}
+	if m.offset != 0 || m.following {
+		m.pendingOffset = 0
+		return
+	}
+	if m.fileSize <= m.pendingOffset {
+		return
+	}
+	offset, err := FindReloadOffset(m.content, m.pendingOffset)
+	m.pendingOffset = 0
+	if err != nil {
+		log.Warn("Could not restore offset: %v", err)
+		return
+	}
+	log.Info("Restoring pending offset: offset=%d", offset)
+	m.moveToOffset(offset)
+}
+
+func (a *app) SaveState() error {
+	if a.store == nil {
+		return nil
+	}
+	for _, m := range a.buffers {
+		if !a.persisted(m) {
+			continue
+		}
+		state := FileState{
+			Offset:     m.offset,
+			Regexes:    saveRegexes(m.regexes),
+			WrapMode:   m.lineWrapMode,
+			PrettyJSON: m.prettyJSON,
+			XPosition:  m.xPosition,
+		}
+		if m.columnMode {
+			// Column mode isn't restored, and its position counts
+			// columns rather than cells.
+			state.XPosition = 0
+		}
+		if err := a.store.SaveFile(m.filename, state); err != nil {
+			return err
+		}
+	}
+	return a.store.SaveHistory(a.history)
 }
 
 const msgLingerDuration = 5 * time.Second
@@ -56,6 +197,8 @@ func (a *app) Initialise() {
 		a.msgSetAt = a.model.msgSetAt
 
 		a.fillScreenBuffer()
+		a.model.updateLineNumbers()
+		a.updateMatchCount(a.model)
 		a.refresh()
 	})
 }
@@ -68,10 +211,19 @@ func (a *app) KeyPress(k Key) {
 	if a.model.longFileOpInProgress {
 		return
 	}
-	if a.model.cmd.Mode == NoCommand {
+	if a.model.timeline != nil {
+		a.timelineKeyPress(k)
+	} else if a.model.cmd.Mode == NoCommand {
 		a.normalModeKeyPress(k)
 	} else {
+		searching := a.model.cmd.Mode == SearchCommand
+		text := a.model.cmd.Text
+		modes := a.model.searchModes
 		a.commandModeKeyPress(k)
+		changed := a.model.cmd.Text != text || a.model.searchModes != modes
+		if searching && a.model.cmd.Mode == SearchCommand && changed {
+			a.incrementalSearch()
+		}
 	}
 }
 
@@ -98,6 +250,13 @@ func (a *app) normalModeKeyPress(k Key) {
 // TODO: This whole thing can be part of the model.
 func (a *app) commandModeKeyPress(k Key) {
 	assert(a.model.cmd.Mode != NoCommand)
+	if a.model.cmd.Mode == MarkCommand || a.model.cmd.Mode == GotoMarkCommand {
+		a.model.markKeyPressed(k)
+		return
+	}
+	if a.model.cmd.Mode == SearchCommand && a.model.toggleSearchMode(k) {
+		return
+	}
 	if len(k) == 1 {
 		b := k[0]
 		if b >= ' ' && b <= '~' {
@@ -110,6 +269,7 @@ func (a *app) commandModeKeyPress(k Key) {
 			switch a.model.cmd.Mode {
 			case SearchCommand:
 				a.model.searchEntered(a.model.cmd.Text)
+				a.model.commitIncSearch()
 			case ColourCommand:
 				a.model.colourEntered(a.model.cmd.Text)
 			case SeekCommand:
@@ -118,12 +278,15 @@ func (a *app) commandModeKeyPress(k Key) {
 					return
 				}
 			case BisectCommand:
-				if err := a.model.bisectEntered(a.model.cmd.Text); err != nil {
-					a.reactor.Stop(err)
-					return
-				}
+				a.bisect(a.model.cmd.Text, false)
+			case TimeCommand:
+				a.bisect(a.model.cmd.Text, true)
 			case QuitCommand:
 				a.quitEntered(a.model.cmd.Text)
+			case ExCommand:
+				a.model.exEntered(a.model.cmd.Text)
+			case FilterCommand:
+				a.model.filterEntered(a.model.cmd.Text)
 			default:
 				assert(false)
 			}
@@ -151,7 +314,7 @@ func (a *app) commandModeKeyPress(k Key) {
 	}
 }
 
-var styles = [...]Style{Default, Black, Red, Green, Yellow, Blue, Magenta, Cyan, White}
+var colours = [...]Colour{Default, Black, Red, Green, Yellow, Blue, Magenta, Cyan, White}
 
 func (a *app) quitEntered(cmd string) {
 	switch cmd {
@@ -165,34 +328,79 @@ func (a *app) quitEntered(cmd string) {
 }
 
 func (a *app) discardBufferedInputAndRepaint() {
+	m := a.model
 	log.Info("Discarding buffered input and repainting screen.")
-	a.model.fwd = nil
-	a.model.bck = nil
+	m.discardLoaded()
 
 	go func() {
-		offset, err := FindReloadOffset(a.model.content, a.model.offset)
+		offset, err := FindReloadOffset(m.content, m.offset)
 		a.reactor.Enque(func() {
 			if err != nil {
 				log.Warn("Could not find reload offset: %v", err)
 				a.reactor.Stop(err)
 				return
 			}
-			a.model.moveToOffset(offset)
+			m.moveToOffset(offset)
 		}, "discard buffered input and repaint")
 	}()
 }
 
+func (a *app) toggleFollowing() {
+	a.model.toggleFollowing()
+	if a.model.following {
+		a.moveToTail(a.model)
+	}
+}
+
+// Moves to the last screenful of the file. If a move is already in progress,
+// then another is scheduled once it completes (so that the file growing
+// during the move isn't missed).
+func (a *app) moveToTail(m *Model) {
+	if m.rows <= 2 || m.cols == 0 {
+		return
+	}
+	if m.tailInProgress {
+		m.tailPending = true
+		return
+	}
+	m.tailInProgress = true
+
+	log.Info("Moving to tail of file.")
+	rows := m.lineRows()
+	rowsFor := m.rowsForLine()
+	go func() {
+		offset, err := FindTailOffset(m.content, rows, rowsFor)
+		a.reactor.Enque(func() {
+			m.tailInProgress = false
+			if err != nil {
+				log.Warn("Could not find tail offset: %v", err)
+				a.reactor.Stop(err)
+				return
+			}
+			if m.tailPending {
+				m.tailPending = false
+				a.moveToTail(m)
+				return
+			}
+			if m.following {
+				m.moveToOffset(offset)
+			}
+		}, "move to tail")
+	}()
+}
+
 func (a *app) moveBottom() {
+	m := a.model
 	log.Info("Jumping to bottom of file.")
 	go func() {
-		offset, err := FindJumpToBottomOffset(a.model.content)
+		offset, err := FindJumpToBottomOffset(m.content)
 		a.reactor.Enque(func() {
 			if err != nil {
 				log.Warn("Could not find jump-to-bottom offset: %v", err)
 				a.reactor.Stop(err)
 				return
 			}
-			a.model.moveToOffset(offset)
+			m.jumpToOffset(offset)
 		}, "move bottom")
 	}()
 }
@@ -205,98 +413,189 @@ const (
 )
 
 func (a *app) fillScreenBuffer() {
+	m := a.model
 
-	if a.fillingScreenBuffer {
+	if m.fillingScreenBuffer {
 		log.Info("Aborting filling screen buffer, already in progress.")
 		return
 	}
 
-	log.Info("Filling screen buffer, has initial state: fwd=%d bck=%d", len(a.model.fwd), len(a.model.bck))
+	log.Info("Filling screen buffer, has initial state: fwd=%d bck=%d", len(m.fwd), len(m.bck))
 
-	if lines := a.model.needsLoadingForward(); lines != 0 {
+	if lines := m.needsLoadingForward(); lines != 0 {
 		a.loadForward(lines)
-	} else if lines := a.model.needsLoadingBackward(); lines != 0 {
+	} else if lines := m.needsLoadingBackward(); lines != 0 {
 		a.loadBackward(lines)
 	} else {
 		log.Info("Screen buffer didn't need filling.")
 	}
 
 	// Prune buffers.
-	neededFwd := min(len(a.model.fwd), a.model.rows*forwardUnloadFactor)
-	a.model.fwd = a.model.fwd[:neededFwd]
-	neededBck := min(len(a.model.bck), a.model.rows*backUnloadFactor)
-	a.model.bck = a.model.bck[:neededBck]
+	neededFwd := min(len(m.fwd), m.rows*forwardUnloadFactor)
+	m.fwd = m.fwd[:neededFwd]
+	neededBck := min(len(m.bck), m.rows*backUnloadFactor)
+	m.bck = m.bck[:neededBck]
 }
 
 func (a *app) loadForward(amount int) {
-	offset := a.model.offset
-	if len(a.model.fwd) > 0 {
-		offset = a.model.fwd[len(a.model.fwd)-1].nextOffset()
+	m := a.model
+	offset := m.offset
+	if len(m.fwd) > 0 {
+		offset = m.fwd[len(m.fwd)-1].nextOffset()
 	}
 	log.Debug("Loading forward: offset=%d amount=%d", offset, amount)
 
-	a.fillingScreenBuffer = true
+	m.fillingScreenBuffer = true
+	gen := m.loadGen
+	v := m.lineView()
+	fileSize := m.fileSize
 	go func() {
-		lines, err := LoadFwd(a.model.content, offset, amount)
+		lines, err := LoadFwd(m.content, offset, amount, v)
 		a.reactor.Enque(func() {
+			if gen != m.loadGen {
+				log.Info("Discarding stale fwd lines.")
+				m.fillingScreenBuffer = false
+				return
+			}
 			if err != nil {
 				log.Warn("Error loading forward: %v", err)
 				a.reactor.Stop(err)
 				return
 			}
-			log.Debug("Got fwd lines: numLines=%d initialFwd=%d initialBck=%d", len(lines), len(a.model.fwd), len(a.model.bck))
-			for _, data := range lines {
-				if (len(a.model.fwd) == 0 && offset == a.model.offset) ||
-					(len(a.model.fwd) > 0 && a.model.fwd[len(a.model.fwd)-1].nextOffset() == offset) {
-					a.model.fwd = append(a.model.fwd, line{offset, data})
+			log.Debug("Got fwd lines: numLines=%d initialFwd=%d initialBck=%d", len(lines), len(m.fwd), len(m.bck))
+			if (len(m.fwd) == 0 && offset == m.offset) ||
+				(len(m.fwd) > 0 && m.fwd[len(m.fwd)-1].nextOffset() == offset) {
+				if len(m.fwd) == 0 && len(lines) > 0 {
+					// The line at the offset may be hidden by a filter, in
+					// which case the next visible line becomes the top.
+					m.offset = lines[0].offset
+				}
+				m.fwd = append(m.fwd, lines...)
+				if len(lines) < amount {
+					m.fwdExhausted = exhausted{offset, fileSize}
 				}
-				offset += len(data)
 			}
-			log.Debug("After adding to data structure: fwd=%d bck=%d", len(a.model.fwd), len(a.model.bck))
-			a.fillingScreenBuffer = false
+			log.Debug("After adding to data structure: fwd=%d bck=%d", len(m.fwd), len(m.bck))
+			m.fillingScreenBuffer = false
 		}, "load forward")
 	}()
 }
 
 func (a *app) loadBackward(amount int) {
-	offset := a.model.offset
-	if len(a.model.bck) > 0 {
-		offset = a.model.bck[len(a.model.bck)-1].offset
+	m := a.model
+	offset := m.offset
+	if len(m.bck) > 0 {
+		offset = m.bck[len(m.bck)-1].offset
 	}
 	log.Debug("Loading backward: offset=%d amount=%d", offset, amount)
 
-	a.fillingScreenBuffer = true
+	m.fillingScreenBuffer = true
+	gen := m.loadGen
+	v := m.lineView()
 	go func() {
-		lines, err := LoadBck(a.model.content, offset, amount)
+		lines, err := LoadBck(m.content, offset, amount, v)
 		a.reactor.Enque(func() {
+			if gen != m.loadGen {
+				log.Info("Discarding stale bck lines.")
+				m.fillingScreenBuffer = false
+				return
+			}
 			if err != nil {
 				log.Warn("Error loading backward: %v", err)
 				a.reactor.Stop(err)
 				return
 			}
-			log.Debug("Got bck lines: numLines=%d initialFwd=%d initialBck=%d", len(lines), len(a.model.fwd), len(a.model.bck))
-			for _, data := range lines {
-				if (len(a.model.bck) == 0 && offset == a.model.offset) ||
-					(len(a.model.bck) > 0 && a.model.bck[len(a.model.bck)-1].offset == offset) {
-					a.model.bck = append(a.model.bck, line{offset - len(data), data})
+			log.Debug("Got bck lines: numLines=%d initialFwd=%d initialBck=%d", len(lines), len(m.fwd), len(m.bck))
+			if (len(m.bck) == 0 && offset == m.offset) ||
+				(len(m.bck) > 0 && m.bck[len(m.bck)-1].offset == offset) {
+				m.bck = append(m.bck, lines...)
+				if len(lines) < amount {
+					m.bckExhausted = exhausted{offset, 0}
 				}
-				offset -= len(data)
 			}
-			log.Debug("After adding to data structure: fwd=%d bck=%d", len(a.model.fwd), len(a.model.bck))
-			a.fillingScreenBuffer = false
+			log.Debug("After adding to data structure: fwd=%d bck=%d", len(m.fwd), len(m.bck))
+			m.fillingScreenBuffer = false
 		}, "load backward")
 	}()
 }
 
 func (a *app) TermSize(rows, cols int, forceRefresh bool) {
 	a.forceRefresh = forceRefresh
-	a.model.rows = rows
-	a.model.cols = cols
+	for _, m := range a.buffers {
+		m.rows = rows
+		m.cols = cols
+		if m.following {
+			a.moveToTail(m)
+		}
+	}
 	log.Info("Term size: rows=%d cols=%d", rows, cols)
 }
 
-func (a *app) FileSize(size int) {
-	a.model.FileSize(size)
+func (a *app) FileSize(buffer int, size int) {
+	m := a.buffers[buffer]
+	m.FileSize(size)
+	a.restorePendingOffset(m)
+	a.extendIndex(m)
+	if m.following {
+		a.moveToTail(m)
+	}
+}
+
+// Extends the line index to cover the content in the background. If an
+// extend is already in progress, then another is scheduled once it completes.
+func (a *app) extendIndex(m *Model) {
+	if m.indexing {
+		m.indexPending = true
+		return
+	}
+	m.indexing = true
+
+	index := m.index
+	cancel := new(Cancellable)
+	m.cancelIndex = cancel
+	go func() {
+		err := index.Extend(m.content, cancel, func() {
+			a.reactor.Enque(func() {}, "index progress")
+		})
+		a.reactor.Enque(func() {
+			if index != m.index {
+				return // Index was reset while extending.
+			}
+			m.indexing = false
+			if err != nil {
+				log.Warn("Could not extend line index: %v", err)
+				a.reactor.Stop(err)
+				return
+			}
+			if m.indexPending {
+				m.indexPending = false
+				a.extendIndex(m)
+			}
+		}, "index extended")
+	}()
+}
+
+func (a *app) ContentReset(buffer int, reason string) {
+	m := a.buffers[buffer]
+	m.ContentReset(reason)
+	a.extendIndex(m)
+	if m.following {
+		a.moveToTail(m)
+	}
+}
+
+func (a *app) switchBuffer(forward bool) {
+	if len(a.buffers) == 1 {
+		a.model.setMessage("no other buffers to switch to")
+		return
+	}
+	delta := len(a.buffers) - 1
+	if forward {
+		delta = 1
+	}
+	a.bufferIdx = (a.bufferIdx + delta) % len(a.buffers)
+	a.model = a.buffers[a.bufferIdx]
+	log.Info("Switched buffer: idx=%d filename=%q", a.bufferIdx, a.model.filename)
 }
 
 func (a *app) refresh() {
@@ -309,82 +608,162 @@ func (a *app) refresh() {
 }
 
 func (a *app) renderScreen() {
-	state := CreateView(&a.model)
+	state := CreateView(a.model, a.bufferIdx, len(a.buffers))
 	a.screen.Write(state, a.forceRefresh)
 	a.forceRefresh = false
 }
 
 func (a *app) jumpToMatch(reverse bool) {
-	re := a.model.currentRE()
+	m := a.model
+	re := m.currentRE()
 	if re == nil {
 		msg := "no regex to jump to"
 		log.Info(msg)
-		a.model.setMessage(msg)
+		m.setMessage(msg)
 		return
 	}
 
-	if len(a.model.fwd) == 0 {
+	if len(m.fwd) == 0 {
 		log.Warn("Cannot search for next match: current line is not loaded.")
 		return
 	}
 
+	m.pauseFollowing()
+
 	var start int
 	if reverse {
-		start = a.model.offset
+		start = m.offset
 	} else {
-		start = a.model.fwd[0].nextOffset()
+		start = m.fwd[0].nextOffset()
 	}
+	resume := searchResume{
+		re:      re.String(),
+		reverse: reverse,
+		origin:  m.offset,
+		loadGen: m.loadGen,
+		from:    start,
+	}
+	resumed := m.searchResume.matches(resume.re, resume.reverse, resume.origin, resume.loadGen)
+	if resumed {
+		log.Info("Resuming cancelled search: offset=%d wrapped=%v", m.searchResume.offset, m.searchResume.wrapped)
+		start = m.searchResume.offset
+		resume.wrapped = m.searchResume.wrapped
+	}
+	m.searchResume = nil
 
-	a.model.longFileOpInProgress = true
-	a.model.cancelLongFileOp.Reset()
-	a.model.msg = ""
+	cancel := new(Cancellable)
+	m.longFileOpInProgress = true
+	m.cancelLongFileOp = cancel
+	m.longFileOpDesc = ""
+	m.searchProgress = searchProgress{offset: start, started: time.Now(), resumed: resumed}
+	m.msg = ""
 
 	log.Info("Searching for next regexp match: regexp=%q", re)
 
-	go a.asyncFindMatch(start, re, reverse)
+	go a.asyncFindMatch(m, cancel, re, m.lineView(), resume, m.searchProgress, m.config.WrapSearch)
 }
 
-func (a *app) asyncFindMatch(start int, re *regexp.Regexp, reverse bool) {
-	defer a.reactor.Enque(func() { a.model.longFileOpInProgress = false }, "find match complete")
+// Searches for the next match, starting at the offset given by the progress.
+// If wrap is set, then once the end (or start) of the file is reached, the
+// search continues from the other end up to where it originally started.
+func (a *app) asyncFindMatch(m *Model, cancel *Cancellable, re *regexp.Regexp, v lineView, resume searchResume, progress searchProgress, wrap bool) {
+	defer a.reactor.Enque(func() {
+		if m.cancelLongFileOp == cancel {
+			m.longFileOpInProgress = false
+		}
+	}, "find match complete")
 
-	var lineReader LineReader
-	if reverse {
-		lineReader = NewBackwardLineReader(a.model.content, start)
-	} else {
-		lineReader = NewForwardLineReader(a.model.content, start)
+	size, err := m.content.Size()
+	if err != nil {
+		a.reactor.Stop(fmt.Errorf("Could not get size: error=%v", err))
+		return
 	}
-
-	offset := start
-	for {
-		if a.model.cancelLongFileOp.Cancelled() {
-			return
+	lastReport := progress.started
+	var scannedBefore int
+	runSearch := func(start int) (int, bool, int, error) {
+		s := &search{
+			content: m.content,
+			re:      re,
+			v:       v,
+			reverse: resume.reverse,
+			size:    int(size),
+			cancel:  cancel,
 		}
-		line, err := lineReader.ReadLine()
-		if err != nil {
-			if err != io.EOF {
-				a.reactor.Stop(fmt.Errorf("Could not read: error=%v", err))
-				return
-			} else {
-				a.reactor.Enque(func() {
-					msg := "regex search complete: no match found"
-					a.model.setMessage(msg)
-				}, "no match found")
+		switch {
+		case !resume.reverse && !resume.wrapped:
+			s.lo, s.hi = start, int(size)
+		case !resume.reverse && resume.wrapped:
+			s.lo, s.hi = start, resume.from
+		case resume.reverse && !resume.wrapped:
+			s.lo, s.hi = 0, start
+		case resume.reverse && resume.wrapped:
+			s.lo, s.hi = resume.from, start
+		}
+		return s.run(func(reached, scanned int) {
+			if time.Since(lastReport) < searchProgressInterval {
 				return
 			}
+			lastReport = time.Now()
+			progress.offset = reached
+			progress.scanned = scannedBefore + scanned
+			p := progress
+			a.reactor.Enque(func() {
+				if m.cancelLongFileOp == cancel {
+					m.searchProgress = p
+				}
+			}, "search progress")
+		})
+	}
+
+	start := progress.offset
+	offset, found, reached, err := runSearch(start)
+	if err == nil && !found && !cancel.Cancelled() && wrap && !resume.wrapped {
+		log.Info("Search reached end of file, wrapping around.")
+		scannedBefore = max(reached-start, start-reached)
+		resume.wrapped = true
+		start = 0
+		if resume.reverse {
+			start = int(size)
 		}
-		if reverse {
-			offset -= len(line)
-		}
-		if re.MatchString(transform(line)) {
-			break
-		}
-		if !reverse {
-			offset += len(line)
-		}
+		offset, found, reached, err = runSearch(start)
+	}
+
+	if cancel.Cancelled() {
+		resume.offset = reached
+		a.reactor.Enque(func() {
+			log.Info("Search cancelled: offset=%d", resume.offset)
+			if m.loadGen != resume.loadGen {
+				return // Content or view changed, so can't resume.
+			}
+			m.searchResume = &resume
+			m.setMessage(fmt.Sprintf("search cancelled at offset %d (repeat the search to resume)", resume.offset))
+		}, "search cancelled")
+		return
+	}
+	if err != nil {
+		a.reactor.Stop(fmt.Errorf("Could not read: error=%v", err))
+		return
+	}
+	if !found {
+		a.reactor.Enque(func() {
+			msg := "regex search complete: no match found"
+			m.setMessage(msg)
+		}, "no match found")
+		return
 	}
 
 	a.reactor.Enque(func() {
+		if cancel.Cancelled() {
+			return
+		}
 		log.Info("Regexp search completed with match.")
-		a.model.moveToOffset(offset)
+		m.jumpToOffset(offset)
+		if resume.wrapped {
+			if resume.reverse {
+				m.setMessage("search hit TOP, continued at BOTTOM")
+			} else {
+				m.setMessage("search hit BOTTOM, continued at TOP")
+			}
+		}
 	}, "match found")
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A bisector does a binary search over the lines of the content, to find the
+// first line at or after some target. The lines must be in order of whatever
+// is being compared (e.g. their timestamps).
+type bisector struct {
+	content Content
+	size    int
+	cancel  *Cancellable
+
+	// Compares a line with the target. Lines that can't be compared (e.g.
+	// because they don't have a timestamp) are skipped.
+	compare func(line string) (atOrAfter bool, ok bool)
+}
+
+// Finds the offset of the first line that is at or after the target. If every
+// line is before the target, then found is false.
+func (b *bisector) search() (offset int, found bool, err error) {
+	lo, hi := 0, b.size
+	for lo < hi {
+		if b.cancel.Cancelled() {
+			return 0, false, nil
+		}
+		mid := lo + (hi-lo)/2
+		_, after, found, err := b.probe(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if !found || after {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	offset, after, found, err := b.probe(lo)
+	return offset, found && after, err
+}
+
+// Finds the first comparable line starting at or after an offset.
+func (b *bisector) probe(from int) (offset int, atOrAfter bool, found bool, err error) {
+	start, err := b.lineStart(from)
+	if err != nil || start >= b.size {
+		return 0, false, false, err
+	}
+	reader := NewForwardLineReader(b.content, start)
+	offset = start
+	for lines := 0; ; lines++ {
+		if lines%1024 == 0 && b.cancel.Cancelled() {
+			return 0, false, false, nil
+		}
+		line, err := reader.ReadLine()
+		if err == io.EOF || offset+len(line) > b.size {
+			return 0, false, false, nil
+		}
+		if err != nil {
+			return 0, false, false, err
+		}
+		if after, ok := b.compare(line); ok {
+			return offset, after, true, nil
+		}
+		offset += len(line)
+	}
+}
+
+// Gives the offset of the first line starting at or after an offset.
+func (b *bisector) lineStart(offset int) (int, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, lineReaderReadSize)
+	for pos := offset - 1; pos < b.size; pos += len(buf) {
+		n, err := b.content.ReadAt(buf[:min(len(buf), b.size-pos)], int64(pos))
+		if err != nil && (err != io.EOF || n == 0) {
+			return 0, err
+		}
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + i + 1, nil
+		}
+	}
+	return b.size, nil
+}
+
+// Moves to the first line at or after a target. The target is a time (see
+// parseTargetTime) when lines are compared by timestamp. Otherwise (unless
+// timeOnly is set) lines are compared with the target as strings. Only
+// lines matching the bisect mask are considered.
+func (a *app) bisect(target string, timeOnly bool) {
+	m := a.model
+	m.pauseFollowing()
+
+	cancel := new(Cancellable)
+	m.longFileOpInProgress = true
+	m.cancelLongFileOp = cancel
+	m.longFileOpDesc = fmt.Sprintf("bisecting for %q (interrupt to cancel)", target)
+	m.msg = ""
+
+	from := m.offset
+	mask := m.config.BisectMask
+	layouts := m.config.TimeLayouts
+	subs := append(substitutions(nil), m.substitutions...)
+	size := m.fileSize
+	go func() {
+		defer a.reactor.Enque(func() {
+			if m.cancelLongFileOp == cancel {
+				m.longFileOpInProgress = false
+			}
+		}, "bisect complete")
+
+		now := time.Now()
+		b := &bisector{content: m.content, size: size, cancel: cancel}
+		text := func(line string) (string, bool) {
+			t := transform(strings.TrimSuffix(line, "\n"), subs)
+			return t, mask.MatchString(t)
+		}
+
+		// Times of day and relative times are relative to the current line
+		// (or now, if there's no timestamp from the current line onwards).
+		var ref time.Time
+		b.compare = func(line string) (bool, bool) {
+			t, ok := text(line)
+			if ok {
+				ref, ok = lineTime(t, layouts, now)
+			}
+			return true, ok
+		}
+		_, _, anyTimes, err := b.probe(from)
+		if err == nil && !anyTimes {
+			_, _, anyTimes, err = b.probe(0)
+			ref = now
+		}
+
+		var desc string
+		var offset int
+		var found bool
+		var msgErr error
+		if err == nil {
+			targetTime, timeErr := parseTargetTime(target, layouts, ref)
+			switch {
+			case timeErr == nil && anyTimes:
+				desc = targetTime.Format("2006-01-02 15:04:05")
+				b.compare = func(line string) (bool, bool) {
+					t, ok := text(line)
+					if !ok {
+						return false, false
+					}
+					lt, ok := lineTime(t, layouts, now)
+					return !lt.Before(targetTime), ok
+				}
+			case timeOnly && timeErr != nil:
+				msgErr = timeErr
+			case timeOnly:
+				msgErr = fmt.Errorf("no timestamps found (see --time-layouts)")
+			default:
+				desc = fmt.Sprintf("%q", target)
+				b.compare = func(line string) (bool, bool) {
+					t, ok := text(line)
+					return t >= target, ok
+				}
+			}
+			if msgErr == nil {
+				offset, found, err = b.search()
+			}
+		}
+
+		a.reactor.Enque(func() {
+			if cancel.Cancelled() {
+				return
+			}
+			switch {
+			case err != nil:
+				log.Warn("Could not bisect: %v", err)
+				m.setMessage(fmt.Sprintf("could not bisect: %v", err))
+			case msgErr != nil:
+				m.setMessage(msgErr.Error())
+			case !found:
+				m.setMessage(fmt.Sprintf("every line is before %s", desc))
+				a.moveBottom()
+			default:
+				log.Info("Bisect found target: target=%s offset=%d", desc, offset)
+				m.jumpToOffset(offset)
+			}
+		}, "bisect result")
+	}()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBisector(t *testing.T) {
+	lines := []string{
+		"a 10\n",
+		"no key\n",
+		"a 20\n",
+		"a 20\n",
+		"  continuation\n",
+		"a 30\n",
+		"a 40\n",
+		"trailing",
+	}
+	content := NewBufferContent()
+	content.Write([]byte(strings.Join(lines, "")))
+	size, _ := content.Size()
+	offsetOf := func(idx int) int {
+		return len(strings.Join(lines[:idx], ""))
+	}
+
+	for _, test := range []struct {
+		target string
+		want   int
+		found  bool
+	}{
+		{"a 00", offsetOf(0), true},
+		{"a 10", offsetOf(0), true},
+		{"a 15", offsetOf(2), true},
+		{"a 20", offsetOf(2), true},
+		{"a 25", offsetOf(5), true},
+		{"a 40", offsetOf(6), true},
+		{"a 50", 0, false},
+	} {
+		target := test.target
+		b := &bisector{
+			content: content,
+			size:    int(size),
+			cancel:  new(Cancellable),
+			compare: func(line string) (bool, bool) {
+				if !strings.HasPrefix(line, "a ") {
+					return false, false
+				}
+				return strings.TrimSpace(line) >= target, true
+			},
+		}
+		got, found, err := b.search()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != test.found || (found && got != test.want) {
+			t.Errorf("target=%q got=%d,%v want=%d,%v", target, got, found, test.want, test.found)
+		}
+	}
+}
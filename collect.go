@@ -18,11 +18,24 @@ func collectInterrupt(r Reactor, a App) {
 	}()
 }
 
-func CollectFileSize(r Reactor, a App, c Content) {
+func CollectFileSize(r Reactor, a App, buffer int, c Content) {
 	go func() {
 		var lastSize int64
 		var sleepFor time.Duration
+		reopener, _ := c.(Reopener)
 		for {
+			if reopener != nil {
+				reason, err := reopener.CheckReopen(lastSize)
+				if err != nil {
+					r.Stop(err)
+					return
+				}
+				if reason != "" {
+					r.Enque(func() { a.ContentReset(buffer, reason) }, "content reset")
+					lastSize = -1 // Ensure the new size is reported.
+				}
+			}
+
 			size, err := c.Size()
 			if err != nil {
 				r.Stop(err)
@@ -32,7 +45,7 @@ func CollectFileSize(r Reactor, a App, c Content) {
 			lastSize = size
 
 			if resized {
-				r.Enque(func() { a.FileSize(int(size)) }, "content size")
+				r.Enque(func() { a.FileSize(buffer, int(size)) }, "content size")
 			}
 
 			if resized {
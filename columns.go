@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values wider than this are truncated in column mode (except in the last
+// column).
+const maxColumnWidth = 40
+
+// Separates adjacent columns.
+const columnSeparator = "  "
+
+// A field is a named value from a line.
+type field struct {
+	name  string
+	value string
+}
+
+// Splits a line into fields. Words of the form key=value (as in logfmt) are
+// named by their key. Other words are named by their position ($1, $2, ...)
+// amongst the words without keys. Values may be double quoted. A JSON object
+// has a field for each of its keys.
+func parseFields(text string) []field {
+	if obj, ok := parseJSONObject(text); ok {
+		fields := make([]field, len(obj))
+		for i, f := range obj {
+			value, _ := jsonValueText(f.value, true)
+			fields[i] = field{f.key, value}
+		}
+		return fields
+	}
+
+	var fields []field
+	var pos int
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		var quoted bool
+		for ; i < len(text) && (quoted || text[i] != ' ' && text[i] != '\t'); i++ {
+			switch {
+			case text[i] == '"':
+				quoted = !quoted
+			case text[i] == '\\' && quoted:
+				i++
+			}
+		}
+		word := text[start:min(i, len(text))]
+		if eq := strings.IndexByte(word, '='); eq > 0 && !strings.Contains(word[:eq], `"`) {
+			fields = append(fields, field{word[:eq], unquoteValue(word[eq+1:])})
+		} else {
+			pos++
+			fields = append(fields, field{"$" + strconv.Itoa(pos), unquoteValue(word)})
+		}
+	}
+	return fields
+}
+
+// Removes the quotes from a double quoted value.
+func unquoteValue(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	if s, err := strconv.Unquote(v); err == nil {
+		if strings.IndexFunc(s, isControl) >= 0 {
+			return v // Keep escapes such as \n, which can't be displayed.
+		}
+		return s
+	}
+	return v[1 : len(v)-1]
+}
+
+// A columnLayout gives the names and widths of the columns for a set of lines.
+type columnLayout struct {
+	names  []string
+	widths []int
+}
+
+// Lays out the fields of some lines as columns. Columns appear in the order
+// in which their names are first seen, except that columns named in order
+// come first. Hidden columns are left out.
+func layoutColumns(lines [][]field, hidden map[string]bool, order []string) columnLayout {
+	var names []string
+	widths := map[string]int{}
+	for _, fields := range lines {
+		seen := map[string]bool{}
+		for _, f := range fields {
+			if hidden[f.name] || seen[f.name] {
+				continue // Only the first of a repeated field is shown.
+			}
+			seen[f.name] = true
+			w, ok := widths[f.name]
+			if !ok {
+				names = append(names, f.name)
+				w = textWidth(f.name)
+			}
+			widths[f.name] = max(w, textWidth(f.value))
+		}
+	}
+
+	var layout columnLayout
+	placed := map[string]bool{}
+	for _, name := range append(append([]string(nil), order...), names...) {
+		if _, ok := widths[name]; !ok || placed[name] {
+			continue
+		}
+		placed[name] = true
+		layout.names = append(layout.names, name)
+		layout.widths = append(layout.widths, min(widths[name], maxColumnWidth))
+	}
+	return layout
+}
+
+// Formats a row, starting from the column at index skip. The value func gives
+// the text for each column.
+func (l columnLayout) format(value func(name string) string, skip int) string {
+	var b strings.Builder
+	for i := skip; i < len(l.names); i++ {
+		v := value(l.names[i])
+		if i == len(l.names)-1 {
+			b.WriteString(v)
+			break
+		}
+		b.WriteString(fitText(v, l.widths[i]))
+		b.WriteString(columnSeparator)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// Formats the fields of a line as a row. If a line has the same field more
+// than once, the first is used.
+func (l columnLayout) row(fields []field, skip int) string {
+	values := map[string]string{}
+	for i := len(fields) - 1; i >= 0; i-- {
+		values[fields[i].name] = fields[i].value
+	}
+	return l.format(func(name string) string { return values[name] }, skip)
+}
+
+// Gives the number of screen cells that text occupies.
+func textWidth(s string) int {
+	var w int
+	for _, r := range s {
+		w += runeWidth(displayRune(r))
+	}
+	return w
+}
+
+// Pads or truncates text to exactly width cells. Truncated text ends with an
+// ellipsis.
+func fitText(s string, width int) string {
+	if w := textWidth(s); w <= width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	var b strings.Builder
+	var w int
+	for _, r := range s {
+		rw := runeWidth(displayRune(r))
+		if w+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	b.WriteString("…")
+	return b.String() + strings.Repeat(" ", width-1-w)
+}
+
+// Parses the fields of the lines on screen, and lays them out as columns.
+func (m *Model) screenColumns() (columnLayout, [][]field) {
+	lines := make([][]field, min(len(m.fwd), m.lineRows()))
+	for i := range lines {
+		text, _ := displayText(m.fwd[i].data, m.ansiColours, m.substitutions, jsonRaw)
+		lines[i] = parseFields(text)
+	}
+	return layoutColumns(lines, m.hiddenColumns, m.columnOrder), lines
+}
+
+func (m *Model) toggleColumnMode() {
+	m.columnMode = !m.columnMode
+	m.xPosition = 0
+	if m.columnMode {
+		m.setMessage("column mode (see :cols, :hide, :show and :order)")
+	} else {
+		m.setMessage("column mode off")
+	}
+}
+
+// Lists the columns on screen, along with any hidden columns.
+func (m *Model) listColumns() {
+	layout, _ := m.screenColumns()
+	msg := "columns: " + strings.Join(layout.names, " ")
+	if len(layout.names) == 0 {
+		msg = "no columns on screen"
+	}
+	if len(m.hiddenColumns) > 0 {
+		var hidden []string
+		for name := range m.hiddenColumns {
+			hidden = append(hidden, name)
+		}
+		sort.Strings(hidden)
+		msg += fmt.Sprintf(" (hidden: %s)", strings.Join(hidden, " "))
+	}
+	m.setMessage(msg)
+}
+
+func (m *Model) hideColumns(names []string) {
+	if len(names) == 0 {
+		m.setMessage("usage: hide <column>...")
+		return
+	}
+	if m.hiddenColumns == nil {
+		m.hiddenColumns = map[string]bool{}
+	}
+	for _, name := range names {
+		m.hiddenColumns[name] = true
+	}
+}
+
+// Shows hidden columns again, or all of them if no names are given.
+func (m *Model) showColumns(names []string) {
+	if len(names) == 0 {
+		m.hiddenColumns = nil
+		return
+	}
+	for _, name := range names {
+		delete(m.hiddenColumns, name)
+	}
+}
+
+// Moves the named columns to the front, in the order given. No names restores
+// the original order.
+func (m *Model) orderColumns(names []string) {
+	m.columnOrder = names
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []field
+	}{
+		{
+			`ts=10:00 level=info msg="server started" port=8080`,
+			[]field{{"ts", "10:00"}, {"level", "info"}, {"msg", "server started"}, {"port", "8080"}},
+		},
+		{
+			`2024-05-01 INFO  "quoted words" key=a=b`,
+			[]field{{"$1", "2024-05-01"}, {"$2", "INFO"}, {"$3", "quoted words"}, {"key", "a=b"}},
+		},
+		{
+			`msg="say \"hi\"" err="a\nb" empty= trailing="unterminated`,
+			[]field{{"msg", `say "hi"`}, {"err", `"a\nb"`}, {"empty", ""}, {"trailing", `"unterminated`}},
+		},
+		{
+			`{"level":"warn","n":2,"obj":{"a":1}}`,
+			[]field{{"level", "warn"}, {"n", "2"}, {"obj", `{"a":1}`}},
+		},
+		{"", nil},
+	} {
+		if got := parseFields(test.in); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("in=%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestLayoutColumns(t *testing.T) {
+	lines := [][]field{
+		parseFields("a=1 b=22 c=x"),
+		parseFields("b=2 d=4444 a=3 a=ignored"),
+		parseFields("c=0123456789012345678901234567890123456789xyz"),
+	}
+	for _, test := range []struct {
+		hidden map[string]bool
+		order  []string
+		skip   int
+		want   []string
+	}{
+		{
+			want: []string{
+				"a  b   c                                         d",
+				"1  22  x",
+				"3  2                                             4444",
+				"       012345678901234567890123456789012345678…",
+			},
+		},
+		{
+			hidden: map[string]bool{"c": true},
+			order:  []string{"d", "missing", "b"},
+			want: []string{
+				"d     b   a",
+				"      22  1",
+				"4444  2   3",
+				"",
+			},
+		},
+		{
+			skip: 3,
+			want: []string{"d", "", "4444", ""},
+		},
+	} {
+		layout := layoutColumns(lines, test.hidden, test.order)
+		got := []string{layout.format(func(name string) string { return name }, test.skip)}
+		for _, fields := range lines {
+			got = append(got, layout.row(fields, test.skip))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("hidden=%v order=%v skip=%d:\ngot  %q\nwant %q", test.hidden, test.order, test.skip, got, test.want)
+		}
+	}
+}
+
+func TestFitText(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"abcdef", 4, "abc…"},
+		{"日本語", 4, "日… "},
+		{"", 2, "  "},
+	} {
+		if got := fitText(test.in, test.width); got != test.want {
+			t.Errorf("in=%q width=%d: got %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
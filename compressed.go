@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+type compression struct {
+	name    string
+	magic   []byte
+	command string // External decompressor, used when there's no stdlib reader.
+	reader  func(io.Reader) (io.Reader, error)
+}
+
+var compressions = []compression{
+	{
+		name:   "gzip",
+		magic:  []byte{0x1f, 0x8b},
+		reader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	},
+	{
+		name:   "bzip2",
+		magic:  []byte("BZh"),
+		reader: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+	},
+	{
+		name:    "xz",
+		magic:   []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		command: "xz",
+	},
+	{
+		name:    "zstd",
+		magic:   []byte{0x28, 0xb5, 0x2f, 0xfd},
+		command: "zstd",
+	},
+}
+
+// Detects the compression used by a file by looking at its magic bytes. Nil
+// is returned if the file isn't compressed (or uses an unknown compression).
+func detectCompression(f *os.File) (*compression, error) {
+	var header [8]byte
+	n, err := f.ReadAt(header[:], 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	for i := range compressions {
+		if bytes.HasPrefix(header[:n], compressions[i].magic) {
+			return &compressions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// NewDecompressedContent decompresses a file into an anonymous spill file in
+// the background. The content grows as decompression progresses (in the same
+// way as a file that's being appended to), so random access is available
+// for the part that's been decompressed so far. The content takes ownership
+// of f, which is closed along with it.
+func NewDecompressedContent(f *os.File, c *compression) (*DecompressedContent, error) {
+	d := &DecompressedContent{file: f}
+	if err := d.start(c); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DecompressedContent) start(c *compression) error {
+	var src io.Reader
+	if c.command != "" {
+		if _, err := exec.LookPath(c.command); err != nil {
+			return fmt.Errorf("%s compressed file requires %q to be installed", c.name, c.command)
+		}
+		cmd := exec.Command(c.command, "-dc")
+		cmd.Stdin = d.file
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		d.cmd = cmd
+		src = stdout
+	} else {
+		var err error
+		src, err = c.reader(d.file)
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	d.spill, err = createSpillFile()
+	if err != nil {
+		return err
+	}
+
+	d.done = make(chan struct{})
+	go d.decompress(src, c.name)
+	return nil
+}
+
+// Creates an anonymous file to hold content that's generated in the
+// background.
+func createSpillFile() (*os.File, error) {
+	spill, err := os.CreateTemp("", "dauntless-spill-")
+	if err != nil {
+		return nil, err
+	}
+	// Unlinking straight away means that the spill file is cleaned up no
+	// matter how dauntless exits.
+	if err := os.Remove(spill.Name()); err != nil {
+		spill.Close()
+		return nil, err
+	}
+	return spill, nil
+}
+
+type DecompressedContent struct {
+	file  *os.File      // The compressed file.
+	cmd   *exec.Cmd     // External decompressor (nil if there isn't one).
+	spill *os.File      // Nil until decompression has started.
+	done  chan struct{} // Closed once decompression has stopped.
+
+	mu     sync.Mutex
+	size   int64 // Number of bytes decompressed into the spill file so far.
+	err    error
+	closed bool
+}
+
+func (d *DecompressedContent) decompress(src io.Reader, name string) {
+	defer close(d.done)
+	written, err := d.copyToSpill(src, name)
+	if d.cmd != nil {
+		if err != nil {
+			// Its output is no longer being read, so it could block.
+			d.cmd.Process.Kill()
+		}
+		if werr := d.cmd.Wait(); werr != nil && err == nil {
+			err = fmt.Errorf("%s decompressor failed: %v", name, werr)
+		}
+	}
+	if err != nil {
+		d.setErr(err)
+		return
+	}
+	log.Info("Decompression complete: format=%s size=%d", name, written)
+}
+
+// Copies decompressed output into the spill file, making it visible as it
+// goes. The number of bytes copied is returned.
+func (d *DecompressedContent) copyToSpill(src io.Reader, name string) (int64, error) {
+	buf := make([]byte, 1<<16)
+	var written int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := d.spill.WriteAt(buf[:n], written); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+			d.mu.Lock()
+			d.size = written
+			d.mu.Unlock()
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			// Common for compressed files that are still being written.
+			log.Warn("Compressed input ended unexpectedly: format=%s", name)
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("could not decompress %s: %v", name, err)
+		}
+	}
+}
+
+// Errors caused by the content being closed aren't recorded.
+func (d *DecompressedContent) setErr(err error) {
+	d.mu.Lock()
+	if !d.closed {
+		d.err = err
+	}
+	d.mu.Unlock()
+}
+
+// Close stops decompression, killing the external decompressor (if there is
+// one) and waiting for it to exit. The compressed and spill files are closed.
+func (d *DecompressedContent) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	if d.cmd != nil {
+		d.cmd.Process.Kill()
+	}
+	d.file.Close()
+	if d.done != nil {
+		<-d.done // The decompressor is waited for by decompress.
+	} else if d.cmd != nil {
+		d.cmd.Wait()
+	}
+	if d.spill == nil {
+		return nil
+	}
+	return d.spill.Close()
+}
+
+func (d *DecompressedContent) Size() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size, d.err
+}
+
+func (d *DecompressedContent) ReadAt(p []byte, off int64) (int, error) {
+	size, _ := d.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	if rem := size - off; int64(len(p)) > rem {
+		n, err := d.spill.ReadAt(p[:rem], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return d.spill.ReadAt(p, off)
+}
+
+func (d *DecompressedContent) Write([]byte) {
+	panic("should not be called")
+}
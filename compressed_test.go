@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecompressedContentGzip(t *testing.T) {
+	log = NullLogger{}
+
+	f, err := os.CreateTemp("", "dauntless-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	const text = "hello\nworld\n"
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	content, err := NewFileContent(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeContents(content)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		size, err := content.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size == int64(len(text)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for decompression: size=%d", size)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lines, err := LoadBck(content, len(text), 2, lineView{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].data != "world\n" || lines[1].data != "hello\n" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+}
+
+func TestDecompressedContentClose(t *testing.T) {
+	log = NullLogger{}
+	if _, err := exec.LookPath("xz"); err != nil {
+		t.Skip("xz isn't installed")
+	}
+
+	f, err := os.CreateTemp("", "dauntless-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	cmd := exec.Command("xz", "-c")
+	cmd.Stdin = strings.NewReader(strings.Repeat("some log line\n", 1<<20))
+	cmd.Stdout = f
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	content, err := NewFileContent(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := content.(*DecompressedContent)
+	if !ok {
+		t.Fatalf("got %T, want *DecompressedContent", content)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.cmd.ProcessState == nil {
+		t.Errorf("decompressor wasn't waited for")
+	}
+	if _, err := d.Size(); err != nil {
+		t.Errorf("got error after close: %v", err)
+	}
+}
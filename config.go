@@ -1,8 +1,132 @@
 package main
 
-import "regexp"
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 type Config struct {
-	WrapPrefix string
-	BisectMask *regexp.Regexp
+	WrapPrefix  string
+	BisectMask  *regexp.Regexp
+	Follow      bool
+	AnsiColours bool
+	TabWidth    int
+	WrapMode    bool
+	WrapGiven   bool // Whether --wrap was given on the command line.
+	WrapSearch  bool
+	TimeLayouts []timeLayout
+	Highlights  []regex
+}
+
+// ConfigFile holds the contents of a config file. Each line is either blank,
+// a comment (starting with '#'), an option or a highlight:
+//
+//	# Options have the same names as the command line flags.
+//	tab-width = 4
+//	wrap-prefix = "  > "
+//
+//	# Highlights give a style (as accepted by the colour command), then
+//	# the regex to preload with that style.
+//	highlight fg:red bold = ERROR
+//	highlight 41 = WARN(ING)?
+//
+// Values may be quoted (using Go syntax) to preserve surrounding whitespace.
+type ConfigFile struct {
+	Options    []ConfigOption
+	Highlights []regex
+}
+
+type ConfigOption struct {
+	Name, Value string
+	Line        int
+}
+
+// The flags that may be set in the config file.
+var configurableFlags = map[string]bool{
+	"wrap-prefix":  true,
+	"bisect-mask":  true,
+	"follow":       true,
+	"R":            true,
+	"tab-width":    true,
+	"wrap":         true,
+	"wrap-search":  true,
+	"time-layouts": true,
+}
+
+// Gives the path of the config file used when none is given explicitly.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "dauntless", "config")
+}
+
+func LoadConfigFile(path string) (ConfigFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ConfigFile{}, err
+	}
+	defer f.Close()
+
+	var cf ConfigFile
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if err := cf.parseLine(scanner.Text(), lineNum); err != nil {
+			return ConfigFile{}, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ConfigFile{}, err
+	}
+	return cf, nil
+}
+
+func (cf *ConfigFile) parseLine(text string, lineNum int) error {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return nil
+	}
+
+	eq := strings.IndexByte(text, '=')
+	if eq == -1 {
+		return fmt.Errorf("expected <name> = <value>: %v", text)
+	}
+	key := strings.TrimSpace(text[:eq])
+	value := strings.TrimSpace(text[eq+1:])
+	if strings.HasPrefix(value, `"`) {
+		var err error
+		value, err = strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("invalid quoted value: %v", text[eq+1:])
+		}
+	}
+
+	if key == "highlight" || strings.HasPrefix(key, "highlight ") {
+		style, err := parseStyle(strings.TrimSpace(strings.TrimPrefix(key, "highlight")))
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		cf.Highlights = append(cf.Highlights, regex{style, re})
+		return nil
+	}
+
+	if !configurableFlags[key] {
+		return fmt.Errorf("unknown option: %v", key)
+	}
+	cf.Options = append(cf.Options, ConfigOption{key, value, lineNum})
+	return nil
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	const contents = `
+# A comment.
+tab-width = 4
+wrap-prefix = "  > "
+
+highlight fg:red bold = ERROR
+highlight 41 = a=b
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cf, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cf.Options) != 2 ||
+		cf.Options[0] != (ConfigOption{"tab-width", "4", 3}) ||
+		cf.Options[1] != (ConfigOption{"wrap-prefix", "  > ", 4}) {
+		t.Errorf("unexpected options: %v", cf.Options)
+	}
+	if len(cf.Highlights) != 2 ||
+		cf.Highlights[0].re.String() != "ERROR" || cf.Highlights[0].style != MixStyle(Red, Default).with(Bold) ||
+		cf.Highlights[1].re.String() != "a=b" || cf.Highlights[1].style != MixStyle(Yellow, Black) {
+		t.Errorf("unexpected highlights: %v", cf.Highlights)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	for i, test := range []struct {
+		contents string
+		errPart  string
+	}{
+		{"tab-width 4", ":1: expected <name> = <value>"},
+		{"\nfoo = bar", ":2: unknown option: foo"},
+		{"highlight blink = x", ":1: unknown style attribute"},
+		{"highlight 11 = (", ":1: error parsing regexp"},
+		{`wrap-prefix = "abc`, ":1: invalid quoted value"},
+	} {
+		path := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), test.errPart) {
+			t.Errorf("%d: got=%v want error containing %q", i, err, test.errPart)
+		}
+	}
+}
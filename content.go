@@ -13,30 +13,126 @@ type Content interface {
 	Write([]byte)
 }
 
-func NewFileContent(filename string) (FileContent, error) {
+// Reopener is implemented by content that is backed by a named file. The
+// file at the name may be replaced (e.g. by log rotation) or truncated while
+// it's being viewed.
+type Reopener interface {
+	// CheckReopen detects if the file has been replaced or truncated since
+	// it was last seen at lastSize bytes. If so, the content is switched
+	// over to the file currently at the name, and a description of what
+	// happened is returned. An empty description means nothing changed.
+	CheckReopen(lastSize int64) (string, error)
+}
+
+// NewFileContent opens a file for viewing. Compressed files are detected and
+// transparently decompressed.
+func NewFileContent(filename string) (Content, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return FileContent{}, err
+		return nil, err
+	}
+	comp, err := detectCompression(f)
+	if err != nil {
+		f.Close()
+		return nil, err
 	}
-	return FileContent{f}, nil
+	if comp != nil {
+		log.Info("Detected compressed file: format=%s", comp.name)
+		d, err := NewDecompressedContent(f, comp)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	return &FileContent{filename: filename, file: f}, nil
 }
 
 type FileContent struct {
-	*os.File
+	mu       sync.Mutex
+	filename string
+	file     *os.File
+
+	// The file that was replaced by the most recent reopen. It's kept
+	// open so that reads already in flight don't fail. It's closed on the
+	// following reopen.
+	prev *os.File
 }
 
-func (f FileContent) Size() (int64, error) {
-	fi, err := f.File.Stat()
+func (f *FileContent) current() *os.File {
+	f.mu.Lock()
+	file := f.file
+	f.mu.Unlock()
+	return file
+}
+
+func (f *FileContent) Size() (int64, error) {
+	fi, err := f.current().Stat()
 	if err != nil {
 		return 0, err
 	}
 	return fi.Size(), nil
 }
 
-func (f FileContent) Write([]byte) {
+func (f *FileContent) ReadAt(p []byte, off int64) (int, error) {
+	return f.current().ReadAt(p, off)
+}
+
+func (f *FileContent) Write([]byte) {
 	panic("should not be called")
 }
 
+func (f *FileContent) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.prev != nil {
+		f.prev.Close()
+	}
+	return f.file.Close()
+}
+
+func (f *FileContent) CheckReopen(lastSize int64) (string, error) {
+	openFI, err := f.current().Stat()
+	if err != nil {
+		return "", err
+	}
+	pathFI, err := os.Stat(f.filename)
+	if err != nil {
+		// The file may be missing momentarily during a rotation. Keep
+		// using the open file until a new one appears.
+		log.Info("Could not stat file by name: %v", err)
+		return "", nil
+	}
+
+	if !os.SameFile(openFI, pathFI) {
+		newFile, err := os.Open(f.filename)
+		if err != nil {
+			return "", err
+		}
+		f.mu.Lock()
+		if f.prev != nil {
+			f.prev.Close()
+		}
+		f.prev = f.file
+		f.file = newFile
+		f.mu.Unlock()
+		return "file was replaced (rotated), reopened from the start", nil
+	}
+
+	if openFI.Size() < lastSize {
+		return "file was truncated, reloaded from the start", nil
+	}
+	return "", nil
+}
+
+// Closes any of the contents that hold open files (or other resources).
+func closeContents(contents ...Content) {
+	for _, c := range contents {
+		if closer, ok := c.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
 func NewBufferContent() *BufferContent {
 	return &BufferContent{}
 }
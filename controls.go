@@ -50,6 +50,17 @@ var controls = []control{
 		action: func(a *app) { a.model.increaseXPosition() },
 	},
 
+	control{
+		keys:   []Key{"]"},
+		desc:   "switch to next buffer",
+		action: func(a *app) { a.switchBuffer(true) },
+	},
+	control{
+		keys:   []Key{"["},
+		desc:   "switch to previous buffer",
+		action: func(a *app) { a.switchBuffer(false) },
+	},
+
 	control{
 		keys:   []Key{"r"},
 		desc:   "force screen refresh",
@@ -66,6 +77,31 @@ var controls = []control{
 		desc:   "move to end of file",
 		action: func(a *app) { a.moveBottom() },
 	},
+	control{
+		keys:   []Key{CtrlO},
+		desc:   "jump back to where the last jump was from",
+		action: func(a *app) { a.model.jumpBack() },
+	},
+	control{
+		keys:   []Key{CtrlN},
+		desc:   "jump forward again (undo a jump back)",
+		action: func(a *app) { a.model.jumpForward() },
+	},
+	control{
+		keys:   []Key{"m"},
+		desc:   "set a mark (followed by a letter)",
+		action: func(a *app) { a.model.StartCommandMode(MarkCommand) },
+	},
+	control{
+		keys:   []Key{"'"},
+		desc:   "jump to a mark (followed by a letter, or ' to jump back)",
+		action: func(a *app) { a.model.StartCommandMode(GotoMarkCommand) },
+	},
+	control{
+		keys:   []Key{"F"},
+		desc:   "toggle follow mode",
+		action: func(a *app) { a.toggleFollowing() },
+	},
 
 	control{
 		keys:   []Key{"/"},
@@ -83,12 +119,40 @@ var controls = []control{
 		action: func(a *app) { a.jumpToMatch(true) },
 	},
 
+	control{
+		keys:   []Key{"&"},
+		desc:   "add a filter regex (empty removes the last filter)",
+		action: func(a *app) { a.model.StartCommandMode(FilterCommand) },
+	},
+	control{
+		keys:   []Key{"*"},
+		desc:   "toggle filters on and off",
+		action: func(a *app) { a.model.toggleFilters() },
+	},
+
 	control{
 		keys:   []Key{"w"},
 		desc:   "toggle line wrap mode",
 		action: func(a *app) { a.model.toggleLineWrapMode() },
 	},
 
+	control{
+		keys:   []Key{"C"},
+		desc:   "toggle column mode (fields of logfmt and space delimited lines)",
+		action: func(a *app) { a.model.toggleColumnMode() },
+	},
+	control{
+		keys:   []Key{"J"},
+		desc:   "toggle pretty printing of JSON lines",
+		action: func(a *app) { a.model.togglePrettyJSON() },
+	},
+
+	control{
+		keys:   []Key{"R"},
+		desc:   "toggle rendering of ANSI colours",
+		action: func(a *app) { a.model.toggleAnsiColours() },
+	},
+
 	control{
 		keys:   []Key{"c"},
 		desc:   "change regex highlight colour",
@@ -110,6 +174,16 @@ var controls = []control{
 		action: func(a *app) { a.model.deleteRegexp() },
 	},
 
+	control{
+		keys:   []Key{":"},
+		desc:   "enter a command (e.g. a line number to go to)",
+		action: func(a *app) { a.model.StartCommandMode(ExCommand) },
+	},
+	control{
+		keys:   []Key{"#"},
+		desc:   "toggle line numbers",
+		action: func(a *app) { a.model.toggleLineNumbers() },
+	},
 	control{
 		keys:   []Key{"s"},
 		desc:   "seek to a percentage",
@@ -117,9 +191,19 @@ var controls = []control{
 	},
 	control{
 		keys:   []Key{"b"},
-		desc:   "bisect line prefix",
+		desc:   "bisect line prefix (or time)",
 		action: func(a *app) { a.model.StartCommandMode(BisectCommand) },
 	},
+	control{
+		keys:   []Key{"t"},
+		desc:   "go to a time",
+		action: func(a *app) { a.model.StartCommandMode(TimeCommand) },
+	},
+	control{
+		keys:   []Key{"T"},
+		desc:   "show a timeline of log volume (and current regex matches)",
+		action: func(a *app) { a.showTimeline() },
+	},
 
 	control{
 		keys:   []Key{"`"},
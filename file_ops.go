@@ -43,6 +43,22 @@ func lineAt(c Content, offset int) (string, int, error) {
 	return bckBytes, offset + len(fwdBytes) - len(bckBytes), err
 }
 
+// Gives the offset just past the last complete (newline terminated) line
+// before size.
+func completeLinesEnd(c Content, size int) (int, error) {
+	line, err := NewBackwardLineReader(c, size).ReadLine()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if line[len(line)-1] == '\n' {
+		return size, nil
+	}
+	return size - len(line), nil
+}
+
 func FindReloadOffset(content Content, offset int) (int, error) {
 	_, n, err := lineAt(content, offset)
 	if err == io.EOF {
@@ -50,3 +66,36 @@ func FindReloadOffset(content Content, offset int) (int, error) {
 	}
 	return n, err
 }
+
+// Finds the offset of the first line of the last screenful of the content.
+// The rowsFor func gives the number of screen rows that a line occupies. A
+// trailing partial line is not counted, since it isn't displayed.
+func FindTailOffset(content Content, rows int, rowsFor func(string) int) (int, error) {
+	size, err := content.Size()
+	if err != nil {
+		return 0, err
+	}
+	offset := int(size)
+	reader := NewBackwardLineReader(content, offset)
+	var counted int
+	for rows > 0 {
+		line, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if offset == int(size) && line[len(line)-1] != '\n' {
+			offset -= len(line)
+			continue
+		}
+		rows -= rowsFor(line)
+		if rows < 0 && counted > 0 {
+			break
+		}
+		offset -= len(line)
+		counted++
+	}
+	return offset, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestFindTailOffset(t *testing.T) {
+	oneRow := func(string) int { return 1 }
+	twoRows := func(string) int { return 2 }
+	for i, test := range []struct {
+		input   string
+		rows    int
+		rowsFor func(string) int
+		want    int
+	}{
+		{"", 3, oneRow, 0},
+		{"a\nb\n", 3, oneRow, 0},
+		{"a\nb\nc\nd\n", 3, oneRow, 2},
+		{"a\nb\nc\nd\npartial", 3, oneRow, 2},
+		{"a\nb\nc\nd\n", 3, twoRows, 6},
+		{"a\nb\nc\nd\n", 1, twoRows, 6},
+	} {
+		content := NewBufferContent()
+		content.Write([]byte(test.input))
+		got, err := FindTailOffset(content, test.rows, test.rowsFor)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got != test.want {
+			t.Errorf("%d: input=%q got=%d want=%d", i, test.input, got, test.want)
+		}
+	}
+}
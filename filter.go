@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A filter hides each line that doesn't match its regex (or, when negated,
+// each line that does).
+type filter struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (f filter) String() string {
+	if f.negate {
+		return "!" + f.re.String()
+	}
+	return f.re.String()
+}
+
+// Filters is a stack of filters, all of which must allow a line for it to be
+// shown.
+type filters []filter
+
+// A lineView captures how lines are seen: the substitutions that rewrite them
+// and the filters that hide them. It's safe to use concurrently.
+type lineView struct {
+	filters filters
+	subs    substitutions
+}
+
+// Gives the text of a line (as displayed) for matching against.
+func (v lineView) text(data string) string {
+	return transform(strings.TrimSuffix(data, "\n"), v.subs)
+}
+
+func (v lineView) allow(data string) bool {
+	if len(v.filters) == 0 {
+		return true
+	}
+	text := v.text(data)
+	for _, f := range v.filters {
+		if f.re.MatchString(text) == f.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses a filter command. A leading '!' negates the filter.
+func parseFilter(cmd string) (filter, error) {
+	var f filter
+	if strings.HasPrefix(cmd, "!") {
+		f.negate = true
+		cmd = cmd[1:]
+	}
+	re, err := regexp.Compile(cmd)
+	if err != nil {
+		return filter{}, err
+	}
+	f.re = re
+	return f, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMoveWithHiddenLines(t *testing.T) {
+	log = NullLogger{}
+
+	// The even lines (a2, a4) are hidden by a filter.
+	visible := []line{{0, "a1\n"}, {6, "a3\n"}, {12, "a5\n"}}
+	var m Model
+	m.fwd = append([]line(nil), visible...)
+
+	m.moveDown()
+	if m.offset != 6 || !reflect.DeepEqual(m.fwd, visible[1:]) || !reflect.DeepEqual(m.bck, visible[:1]) {
+		t.Fatalf("after down: offset=%d fwd=%v bck=%v", m.offset, m.fwd, m.bck)
+	}
+	m.moveDown()
+	if m.offset != 12 || !reflect.DeepEqual(m.fwd, visible[2:]) || !reflect.DeepEqual(m.bck, []line{visible[1], visible[0]}) {
+		t.Fatalf("after down: offset=%d fwd=%v bck=%v", m.offset, m.fwd, m.bck)
+	}
+	m.moveUp()
+	m.moveUp()
+	if m.offset != 0 || !reflect.DeepEqual(m.fwd, visible) || len(m.bck) != 0 {
+		t.Fatalf("after up: offset=%d fwd=%v bck=%v", m.offset, m.fwd, m.bck)
+	}
+}
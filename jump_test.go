@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestJumpList(t *testing.T) {
+	log = NullLogger{}
+	var m Model
+	m.jumpToOffset(100)
+	m.jumpToOffset(200)
+	m.jumpToOffset(300)
+
+	for _, tc := range []struct {
+		action string
+		offset int
+	}{
+		{"back", 200},
+		{"back", 100},
+		{"back", 0},
+		{"back", 0},
+		{"forward", 100},
+		{"forward", 200},
+		{"forward", 300},
+		{"forward", 300},
+		{"back", 200},
+	} {
+		if tc.action == "back" {
+			m.jumpBack()
+		} else {
+			m.jumpForward()
+		}
+		if m.offset != tc.offset {
+			t.Fatalf("after %s: offset=%d want=%d", tc.action, m.offset, tc.offset)
+		}
+	}
+
+	// Jumping from the middle of the list discards the forward entries.
+	m.jumpToOffset(500)
+	m.jumpForward()
+	if m.offset != 500 {
+		t.Fatalf("offset=%d want=500", m.offset)
+	}
+	m.jumpBack()
+	if m.offset != 200 {
+		t.Fatalf("offset=%d want=200", m.offset)
+	}
+}
+
+func TestJumpForwardPausesFollowing(t *testing.T) {
+	log = NullLogger{}
+	var m Model
+	m.jumpToOffset(100)
+	m.jumpBack()
+	m.following = true
+	m.jumpForward()
+	if m.following {
+		t.Errorf("still following after jumping forward")
+	}
+}
+
+func TestJumpListLineNumbers(t *testing.T) {
+	log = NullLogger{}
+	m := Model{lineNums: map[int]int{100: 4}}
+	m.jumpToOffset(100)
+	m.jumpToOffset(200)
+	lines := jumpListLines(&m)
+	if want := "  offset 100 (line 5)"; lines[2] != want {
+		t.Errorf("got %q, want %q", lines[2], want)
+	}
+}
+
+func TestJumpBackToOnlyJump(t *testing.T) {
+	log = NullLogger{}
+	var m Model
+	m.jumpToOffset(100)
+	m.moveToOffset(0)
+	m.jumpBack()
+	if m.offset != 0 {
+		t.Errorf("offset=%d want=0", m.offset)
+	}
+	if want := "already at oldest jump"; m.msg != want {
+		t.Errorf("msg=%q want=%q", m.msg, want)
+	}
+}
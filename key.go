@@ -16,6 +16,11 @@ const (
 	PageUpKey     Key = "\x1b[5~"
 	PageDownKey   Key = "\x1b[6~"
 	ShiftTab      Key = "\x1b[Z"
+	CtrlN         Key = "\x0e"
+	CtrlO         Key = "\x0f"
+	CtrlR         Key = "\x12"
+	CtrlT         Key = "\x14"
+	CtrlW         Key = "\x17"
 )
 
 func (k Key) String() string {
@@ -25,6 +30,8 @@ func (k Key) String() string {
 			return string(k)
 		} else if k[0] == '\t' {
 			return "<tab>"
+		} else if k[0] >= 1 && k[0] <= 26 {
+			return fmt.Sprintf("<ctrl-%c>", 'a'+k[0]-1)
 		} else {
 			return fmt.Sprintf("0x%02X", k[0])
 		}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The start of every lineIndexInterval'th line is recorded as a checkpoint.
+// Converting between line numbers and offsets requires reading at most this
+// many lines from the nearest checkpoint.
+const lineIndexInterval = 64
+
+const lineIndexReadSize = 1 << 16
+
+// LineIndex maps between (zero based) line numbers and offsets. It's built
+// in the background, and extended as the content grows. It's safe for
+// concurrent use.
+type LineIndex struct {
+	mu          sync.Mutex
+	checkpoints []int // checkpoints[i] is the offset of line i*lineIndexInterval.
+	lines       int   // Number of complete lines indexed.
+	indexed     int   // Offset up to which the content has been indexed.
+}
+
+func NewLineIndex() *LineIndex {
+	return &LineIndex{checkpoints: []int{0}}
+}
+
+// Extend indexes the content from where the previous extend left off, up to
+// the current size of the content. The progress func is called periodically.
+func (x *LineIndex) Extend(c Content, cancel *Cancellable, progress func()) error {
+	sz, err := c.Size()
+	if err != nil {
+		return err
+	}
+	size := int(sz)
+
+	x.mu.Lock()
+	offset := x.indexed
+	x.mu.Unlock()
+
+	buf := make([]byte, lineIndexReadSize)
+	lastProgress := time.Now()
+	for offset < size {
+		if cancel.Cancelled() {
+			return nil
+		}
+		n, err := c.ReadAt(buf[:min(len(buf), size-offset)], int64(offset))
+		if err != nil && (err != io.EOF || n == 0) {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		x.mu.Lock()
+		for i, b := range buf[:n] {
+			if b == '\n' {
+				x.lines++
+				if x.lines%lineIndexInterval == 0 {
+					x.checkpoints = append(x.checkpoints, offset+i+1)
+				}
+			}
+		}
+		offset += n
+		x.indexed = offset
+		x.mu.Unlock()
+
+		if time.Since(lastProgress) > 100*time.Millisecond {
+			progress()
+			lastProgress = time.Now()
+		}
+	}
+	return nil
+}
+
+// Lines gives the number of complete lines indexed so far, and the offset
+// up to which the content has been indexed.
+func (x *LineIndex) Lines() (lines int, indexed int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.lines, x.indexed
+}
+
+// LineNumber finds the line number of the line starting at offset. False is
+// returned if the index hasn't reached the offset yet.
+func (x *LineIndex) LineNumber(c Content, offset int) (int, bool, error) {
+	x.mu.Lock()
+	if offset > x.indexed {
+		x.mu.Unlock()
+		return 0, false, nil
+	}
+	cp := sort.Search(len(x.checkpoints), func(i int) bool {
+		return x.checkpoints[i] > offset
+	}) - 1
+	start := x.checkpoints[cp]
+	x.mu.Unlock()
+
+	num := cp * lineIndexInterval
+	reader := NewForwardLineReader(c, start)
+	for start < offset {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return 0, false, err
+		}
+		start += len(line)
+		num++
+	}
+	return num, true, nil
+}
+
+// LineOffset finds the offset of the start of a line. False is returned if
+// the line hasn't been indexed (yet).
+func (x *LineIndex) LineOffset(c Content, num int) (int, bool, error) {
+	x.mu.Lock()
+	if num < 0 || num >= x.lines {
+		x.mu.Unlock()
+		return 0, false, nil
+	}
+	offset := x.checkpoints[num/lineIndexInterval]
+	x.mu.Unlock()
+
+	reader := NewForwardLineReader(c, offset)
+	for i := 0; i < num%lineIndexInterval; i++ {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return 0, false, err
+		}
+		offset += len(line)
+	}
+	return offset, true, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineIndex(t *testing.T) {
+	var buf strings.Builder
+	var offsets []int
+	for i := 0; i < lineIndexInterval*3+5; i++ {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	content := NewBufferContent()
+	content.Write([]byte(buf.String()))
+	content.Write([]byte("partial"))
+
+	index := NewLineIndex()
+	if err := index.Extend(content, new(Cancellable), func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if lines, indexed := index.Lines(); lines != len(offsets) || indexed != buf.Len()+len("partial") {
+		t.Fatalf("lines=%d indexed=%d", lines, indexed)
+	}
+
+	for num, offset := range offsets {
+		gotNum, ok, err := index.LineNumber(content, offset)
+		if err != nil || !ok || gotNum != num {
+			t.Errorf("LineNumber(%d): got=%d ok=%t err=%v want=%d", offset, gotNum, ok, err, num)
+		}
+		gotOffset, ok, err := index.LineOffset(content, num)
+		if err != nil || !ok || gotOffset != offset {
+			t.Errorf("LineOffset(%d): got=%d ok=%t err=%v want=%d", num, gotOffset, ok, err, offset)
+		}
+	}
+	if _, ok, _ := index.LineOffset(content, len(offsets)); ok {
+		t.Errorf("expected partial line to not be indexed")
+	}
+
+	// Completing the partial line should extend the index.
+	content.Write([]byte("\n"))
+	if err := index.Extend(content, new(Cancellable), func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if lines, _ := index.Lines(); lines != len(offsets)+1 {
+		t.Errorf("lines after extend=%d", lines)
+	}
+}
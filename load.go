@@ -2,20 +2,31 @@ package main
 
 import "io"
 
-func LoadFwd(content Content, offset int, count int) ([]string, error) {
+// LoadFwd loads up to count lines starting at offset, skipping any that are
+// hidden by the filters.
+func LoadFwd(content Content, offset int, count int, v lineView) ([]line, error) {
 	r := NewForwardLineReader(content, offset)
-	return load(count, r)
+	return load(count, r, v, func(data string) int {
+		start := offset
+		offset += len(data)
+		return start
+	})
 }
 
-func LoadBck(content Content, offset int, count int) ([]string, error) {
+// LoadBck loads up to count lines ending at offset (latest first), skipping
+// any that are hidden by the filters.
+func LoadBck(content Content, offset int, count int, v lineView) ([]line, error) {
 	r := NewBackwardLineReader(content, offset)
-	return load(count, r)
+	return load(count, r, v, func(data string) int {
+		offset -= len(data)
+		return offset
+	})
 }
 
-func load(count int, r LineReader) ([]string, error) {
-	lines := make([]string, 0, count)
-	for i := 0; i < count; i++ {
-		line, err := r.ReadLine()
+func load(count int, r LineReader, v lineView, lineOffset func(string) int) ([]line, error) {
+	lines := make([]line, 0, count)
+	for len(lines) < count {
+		data, err := r.ReadLine()
 		if err != nil {
 			if err == io.EOF {
 				return lines, nil
@@ -23,7 +34,10 @@ func load(count int, r LineReader) ([]string, error) {
 				return nil, err
 			}
 		}
-		lines = append(lines, string(line))
+		offset := lineOffset(data)
+		if v.allow(data) {
+			lines = append(lines, line{offset, data})
+		}
 	}
 	return lines, nil
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadWithFilters(t *testing.T) {
+	const input = "a1\nb2\na3\nb4\na5\n"
+	content := NewBufferContent()
+	content.Write([]byte(input))
+
+	only, err := parseFilter("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hide, err := parseFilter("!5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range []struct {
+		v    lineView
+		fwd  bool
+		from int
+		want []line
+	}{
+		{lineView{}, true, 3, []line{{3, "b2\n"}, {6, "a3\n"}}},
+		{lineView{filters: filters{only}}, true, 3, []line{{6, "a3\n"}, {12, "a5\n"}}},
+		{lineView{filters: filters{only, hide}}, true, 3, []line{{6, "a3\n"}}},
+		{lineView{filters: filters{only}}, false, len(input), []line{{12, "a5\n"}, {6, "a3\n"}}},
+		{lineView{filters: filters{only, hide}}, false, 12, []line{{6, "a3\n"}, {0, "a1\n"}}},
+	} {
+		var got []line
+		if test.fwd {
+			got, err = LoadFwd(content, test.from, 2, test.v)
+		} else {
+			got, err = LoadBck(content, test.from, 2, test.v)
+		}
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got=%v want=%v", i, got, test.want)
+		}
+	}
+}
+
+func TestFilterAllowsIgnoresNewline(t *testing.T) {
+	f, err := parseFilter("x$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(lineView{filters: filters{f}}).allow(strings.Repeat("x", 3) + "\n") {
+		t.Errorf("expected line to be allowed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"syscall"
 
 	"golang.org/x/crypto/ssh/terminal"
@@ -20,6 +21,15 @@ func main() {
 	vFlag := flag.Bool("version", false, "version")
 	wrapPrefix := flag.String("wrap-prefix", "", "prefix string for wrapped lines")
 	bisectMask := flag.String("bisect-mask", "", "only consider lines matching this regex when bisecting")
+	follow := flag.Bool("follow", false, "start in follow mode (stick to the end of the file as it grows)")
+	ansiColours := flag.Bool("R", false, "render ANSI colour escape sequences (like less -R)")
+	tabWidth := flag.Int("tab-width", 8, "number of columns between tab stops")
+	wrap := flag.Bool("wrap", false, "start in line wrap mode")
+	merge := flag.Bool("merge", false, "interleave the lines of all files by timestamp, in a single view (rotated files are marked and appended)")
+	wrapSearch := flag.Bool("wrap-search", false, "wrap searches around the start and end of the file")
+	timeLayoutsSpec := flag.String("time-layouts", defaultTimeLayouts, "'|' separated timestamp layouts (rfc3339, datetime, syslog, clf, epoch, epoch-ms, or Go layouts)")
+	noState := flag.Bool("no-state", false, "don't restore or save per-file session state and command history")
+	configPath := flag.String("config", "", "config file (default $XDG_CONFIG_HOME/dauntless/config)")
 	helpFlag := flag.Bool("help", false, "display help")
 	flag.Parse()
 
@@ -57,57 +67,142 @@ func main() {
 		}
 	}
 
+	// Checked before the config file is loaded, since it sets flags too.
+	var wrapGiven bool
+	flag.Visit(func(f *flag.Flag) { wrapGiven = wrapGiven || f.Name == "wrap" })
+
+	highlights, err := loadConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Flags are all checked before any files are opened, so that there's
+	// nothing to clean up if they're invalid.
+	mask, err := regexp.Compile(*bisectMask)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not compile regex: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *tabWidth < 1 {
+		fmt.Fprintf(os.Stderr, "Tab width must be at least 1: %d\n", *tabWidth)
+		os.Exit(1)
+	}
+
+	timeLayouts, err := parseTimeLayouts(*timeLayoutsSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not parse time layouts: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *merge && len(flag.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "Missing filenames to merge\n")
+		os.Exit(1)
+	}
+
 	reactor := NewReactor()
-	var filename string
-	var content Content
+	var filenames []string
+	var contents []Content
 
-	switch len(flag.Args()) {
-	case 0:
+	if len(flag.Args()) == 0 {
 		if terminal.IsTerminal(syscall.Stdin) {
 			fmt.Fprintf(os.Stderr, "Missing filename (use \"dauntless --help\" for usage)\n")
 			os.Exit(1)
 		}
-		filename = "stdin"
 		buffContent := NewBufferContent()
 		CollectContent(os.Stdin, reactor, buffContent)
-		content = buffContent
-	case 1:
-		filename = flag.Args()[0]
-		var err error
-		content, err = NewFileContent(filename)
+		filenames = append(filenames, "stdin")
+		contents = append(contents, buffContent)
+	}
+	for _, filename := range flag.Args() {
+		content, err := NewFileContent(filename)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not open file %s: %s", filename, err)
+			fmt.Fprintf(os.Stderr, "Could not open file %s: %s\n", filename, err)
+			closeContents(contents...)
 			os.Exit(1)
 		}
-	default:
-		flag.Usage()
-		os.Exit(1)
+		filenames = append(filenames, filename)
+		contents = append(contents, content)
 	}
 
-	mask, err := regexp.Compile(*bisectMask)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not compile regex: %v\n", err)
-		os.Exit(1)
+	if *merge {
+		merged, err := NewMergedContent(contents, filenames, timeLayouts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not merge files: %v\n", err)
+			closeContents(contents...)
+			os.Exit(1)
+		}
+		contents = []Content{merged}
+		filenames = []string{strings.Join(filenames, "+")}
 	}
 
-	config := Config{*wrapPrefix, mask}
+	config := Config{*wrapPrefix, mask, *follow, *ansiColours, *tabWidth, *wrap, wrapGiven, *wrapSearch, timeLayouts, highlights}
+
+	var store *StateStore
+	if !*noState {
+		if dir, err := defaultStateDir(); err != nil {
+			log.Warn("Could not find state directory: %v", err)
+		} else {
+			store = NewStateStore(dir)
+		}
+	}
 
 	enterAlt()
 	ttyState := enterRaw()
 	screen := NewTermScreen(os.Stdout, reactor)
-	app := NewApp(reactor, content, filename, screen, config)
+	app := NewApp(reactor, contents, filenames, screen, config, store)
 	reactor.Enque(app.Initialise, "initialise")
-	CollectFileSize(reactor, app, content)
+	for i, content := range contents {
+		CollectFileSize(reactor, app, i, content)
+	}
 	collectInterrupt(reactor, app)
 	collectInput(reactor, app)
 	CollectTermSize(reactor, app)
 	err = reactor.Run()
+	closeContents(contents...)
 
 	ttyState.leaveRaw()
 	leaveAlt()
 
+	if saveErr := app.SaveState(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Could not save session state: %v\n", saveErr)
+	}
+
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// Loads the config file, using it to set any of the flags that weren't given
+// on the command line. The highlights from the file are returned. It's not an
+// error for the file to be missing, unless its path was given explicitly.
+func loadConfigFile(path string) ([]regex, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return nil, nil
+		}
+	}
+	cf, err := LoadConfigFile(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	onCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+	for _, opt := range cf.Options {
+		if onCommandLine[opt.Name] {
+			continue
+		}
+		if err := flag.Set(opt.Name, opt.Value); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid value for %s: %v", path, opt.Line, opt.Name, err)
+		}
+	}
+	return cf.Highlights, nil
+}
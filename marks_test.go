@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestMarks(t *testing.T) {
+	log = NullLogger{}
+	var m Model
+	m.fwd = []line{{0, "first\n"}}
+	m.StartCommandMode(MarkCommand)
+	m.markKeyPressed("a")
+	if m.cmd.Mode != NoCommand {
+		t.Fatalf("still in command mode: %v", m.cmd.Mode)
+	}
+	if mk := m.marks['a']; mk.offset != 0 || mk.preview != "first" {
+		t.Fatalf("unexpected mark: %+v", mk)
+	}
+
+	m.fwd = nil
+	m.moveToOffset(50)
+	m.StartCommandMode(MarkCommand)
+	m.markKeyPressed("B")
+	m.moveToOffset(100)
+
+	for _, tc := range []struct {
+		key    Key
+		offset int
+	}{
+		{"a", 0},
+		{"B", 50},
+		{"z", 50},  // Not set, so doesn't move.
+		{"1", 50},  // Invalid name.
+		{"'", 0},   // Jumps back.
+		{"'", 100}, // Where we were before the first jump to a mark.
+	} {
+		m.StartCommandMode(GotoMarkCommand)
+		m.markKeyPressed(tc.key)
+		if m.offset != tc.offset {
+			t.Fatalf("after %v: offset=%d want=%d", tc.key, m.offset, tc.offset)
+		}
+	}
+
+	if name := m.markAt(50); name != 'B' {
+		t.Errorf("markAt: got=%q want=%q", name, 'B')
+	}
+	if names := string(m.markNames()); names != "Ba" {
+		t.Errorf("markNames: got=%q want=%q", names, "Ba")
+	}
+}
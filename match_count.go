@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Matching lines beyond this many aren't recorded (to bound memory use), so
+// only a lower bound on the number of matches is known.
+const maxCountedMatches = 1 << 20
+
+// A count of the lines matching a regex, built in the background and extended
+// as the file grows.
+type matchCount struct {
+	key string // Identifies the regex and line view that were counted.
+	re  *regexp.Regexp
+	v   lineView
+
+	offsets  []int // Offsets of the matching lines, in order.
+	counted  int   // Lines before this offset have been counted.
+	sizeSeen int   // File size when the count last caught up.
+	overflow bool  // Stopped counting, since there were too many matches.
+
+	counting bool
+	cancel   *Cancellable
+}
+
+func matchCountKey(re *regexp.Regexp, v lineView) string {
+	return fmt.Sprintf("%s\x00%v\x00%v", re, v.filters, v.subs)
+}
+
+// Gives the (one based) number of the match at an offset, or 0 if there isn't
+// a match there.
+func (c *matchCount) matchNumber(offset int) int {
+	i := sort.SearchInts(c.offsets, offset)
+	if i < len(c.offsets) && c.offsets[i] == offset {
+		return i + 1
+	}
+	return 0
+}
+
+// Describes the position of the match at an offset amongst all matches,
+// e.g. "match 3 of 17".
+func (c *matchCount) describe(offset int) string {
+	total := fmt.Sprint(len(c.offsets))
+	if c.overflow {
+		total = fmt.Sprintf(">%d", len(c.offsets))
+	} else if c.counting {
+		total += "+"
+	}
+	if num := c.matchNumber(offset); num != 0 {
+		return fmt.Sprintf("match %d of %s", num, total)
+	}
+	if offset >= c.counted && c.counting {
+		return fmt.Sprintf("match ? of %s", total)
+	}
+	return fmt.Sprintf("%s matches", total)
+}
+
+// Counts the matches of the current regex, restarting the count if the regex,
+// filters or substitutions have changed, and extending it if the file has
+// grown.
+func (a *app) updateMatchCount(m *Model) {
+	re := m.currentRE()
+	if re == nil {
+		m.resetMatchCount()
+		return
+	}
+	v := m.lineView()
+	key := matchCountKey(re, v)
+	if m.matchCount == nil || m.matchCount.key != key {
+		m.resetMatchCount()
+		m.matchCount = &matchCount{key: key, re: re, v: v}
+	}
+
+	c := m.matchCount
+	if c.counting || c.overflow || m.fileSize <= c.sizeSeen {
+		return
+	}
+	c.counting = true
+	cancel := new(Cancellable)
+	c.cancel = cancel
+	size := m.fileSize
+	from := c.counted
+	already := len(c.offsets)
+
+	go func() {
+		var found []int
+		report := func(offset int, done bool) {
+			batch := found
+			found = nil
+			a.reactor.Enque(func() {
+				if m.matchCount != c {
+					return // Count was restarted.
+				}
+				c.offsets = append(c.offsets, batch...)
+				c.counted = offset
+				if len(c.offsets) >= maxCountedMatches {
+					c.overflow = true
+				}
+				if done {
+					c.counting = false
+					c.sizeSeen = size
+				}
+			}, "match count progress")
+		}
+
+		reader := NewForwardLineReader(m.content, from)
+		offset := from
+		lastReport := time.Now()
+		for lines := 0; ; lines++ {
+			if cancel.Cancelled() {
+				return
+			}
+			line, err := reader.ReadLine()
+			if err != nil {
+				if err != io.EOF {
+					log.Warn("Could not count matches: %v", err)
+				}
+				report(offset, true)
+				return
+			}
+			if c.v.allow(line) && c.re.MatchString(c.v.text(line)) {
+				found = append(found, offset)
+				if already++; already >= maxCountedMatches {
+					report(offset, true)
+					return
+				}
+			}
+			offset += len(line)
+			if lines%1024 == 0 && time.Since(lastReport) > searchProgressInterval {
+				lastReport = time.Now()
+				report(offset, false)
+			}
+		}
+	}()
+}
+
+func (m *Model) resetMatchCount() {
+	if m.matchCount != nil && m.matchCount.cancel != nil {
+		m.matchCount.cancel.Cancel()
+	}
+	m.matchCount = nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestMatchCountDescribe(t *testing.T) {
+	for i, test := range []struct {
+		c      matchCount
+		offset int
+		want   string
+	}{
+		{matchCount{offsets: []int{10, 20, 30}, counted: 40}, 20, "match 2 of 3"},
+		{matchCount{offsets: []int{10, 20, 30}, counted: 40}, 25, "3 matches"},
+		{matchCount{offsets: []int{10, 20}, counted: 25, counting: true}, 10, "match 1 of 2+"},
+		{matchCount{offsets: []int{10, 20}, counted: 25, counting: true}, 30, "match ? of 2+"},
+		{matchCount{offsets: []int{10, 20}, counted: 25, overflow: true}, 30, ">2 matches"},
+		{matchCount{}, 0, "0 matches"},
+	} {
+		if got := test.c.describe(test.offset); got != test.want {
+			t.Errorf("%d: got=%q want=%q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Tagger is implemented by content whose lines start with a tag saying where
+// they came from.
+type Tagger interface {
+	// TagStyle gives the length of the tag at the start of a line, and the
+	// style to display it with. The length is 0 if there's no tag.
+	TagStyle(line string) (int, Style)
+}
+
+// Colours used for the tags of merged files, in order.
+var mergeTagColours = []Colour{Cyan, Green, Yellow, Magenta, Blue, Red}
+
+// Size of the merged output that's buffered before being written to the spill
+// file (and becoming visible).
+const mergeFlushSize = 1 << 16
+
+// A mergeSource is one of the files being merged.
+type mergeSource struct {
+	content Content
+	tag     string    // Prefix added to each of its lines.
+	offset  int       // Lines before this have been merged.
+	last    time.Time // Time of the most recent timestamped line.
+
+	reader *ForwardLineReader
+	peeked string   // A line that has been read but not yet merged.
+	hint   timeHint // Where the most recent timestamp was found.
+}
+
+// NewMergedContent interleaves the lines of several contents by timestamp. The
+// merge happens into an anonymous spill file in the background, and lines
+// appended to any of the contents are merged in as they arrive. Each line is
+// prefixed by a tag naming the file it came from.
+//
+// Lines without a timestamp (e.g. stack traces) are kept with the timestamped
+// line before them. Lines that arrive late are appended rather than being
+// inserted back in time.
+func NewMergedContent(contents []Content, filenames []string, layouts []timeLayout) (*MergedContent, error) {
+	mc, err := newMergedContent(contents, filenames, layouts)
+	if err != nil {
+		return nil, err
+	}
+	go mc.follow()
+	return mc, nil
+}
+
+func newMergedContent(contents []Content, filenames []string, layouts []timeLayout) (*MergedContent, error) {
+	spill, err := createSpillFile()
+	if err != nil {
+		return nil, err
+	}
+	mc := &MergedContent{spill: spill, layouts: layouts}
+	tags := mergeTags(filenames)
+	for i, c := range contents {
+		mc.sources = append(mc.sources, &mergeSource{content: c, tag: tags[i]})
+	}
+	return mc, nil
+}
+
+// Gives a tag for each file, padded to the same width. Tags are the base
+// names of the files, unless that would be ambiguous.
+func mergeTags(filenames []string) []string {
+	names := make([]string, len(filenames))
+	seen := map[string]bool{}
+	unique := true
+	for i, f := range filenames {
+		names[i] = filepath.Base(f)
+		unique = unique && !seen[names[i]]
+		seen[names[i]] = true
+	}
+	if !unique {
+		copy(names, filenames)
+	}
+	var width int
+	for _, n := range names {
+		width = max(width, len(n))
+	}
+	for i, n := range names {
+		names[i] = fmt.Sprintf("%-*s | ", width, n)
+	}
+	return names
+}
+
+type MergedContent struct {
+	spill   *os.File
+	sources []*mergeSource
+	layouts []timeLayout
+	written int
+	out     []byte
+
+	mu   sync.Mutex
+	size int64 // Number of bytes merged into the spill file so far.
+	err  error
+}
+
+// Merges new lines from the sources, polling for more as they grow.
+func (mc *MergedContent) follow() {
+	var sleepFor time.Duration
+	for {
+		grew, err := mc.mergeAvailable()
+		if err != nil {
+			log.Warn("Could not merge: %v", err)
+			mc.closeSources()
+			mc.mu.Lock()
+			mc.err = err
+			mc.mu.Unlock()
+			return
+		}
+		if grew {
+			sleepFor = 0
+		} else {
+			sleepFor = 2 * (sleepFor + time.Millisecond)
+			const maxSleep = time.Second
+			if sleepFor > maxSleep {
+				sleepFor = maxSleep
+			}
+		}
+		time.Sleep(sleepFor)
+	}
+}
+
+// Merges all of the complete lines currently available from the sources.
+func (mc *MergedContent) mergeAvailable() (bool, error) {
+	now := time.Now()
+	for _, src := range mc.sources {
+		if reopener, ok := src.content.(Reopener); ok {
+			reason, err := reopener.CheckReopen(int64(src.offset))
+			if err != nil {
+				return false, err
+			}
+			if reason != "" {
+				log.Info("Merge source reset: tag=%q reason=%q", src.tag, reason)
+				// Lines already merged from the old file stay, so mark
+				// where the new file's lines start.
+				mc.appendEntry(src.tag, fmt.Sprintf("--- %s ---\n", reason))
+				src.offset = 0
+				src.last = time.Time{}
+			}
+		}
+		src.reader = NewForwardLineReader(src.content, src.offset)
+		src.peeked = ""
+	}
+
+	type head struct {
+		entry string
+		t     time.Time
+		ok    bool
+	}
+	heads := make([]head, len(mc.sources))
+	next := func(i int) error {
+		entry, t, ok, err := mc.sources[i].readEntry(mc.layouts, now)
+		heads[i] = head{entry, t, ok}
+		return err
+	}
+	for i := range mc.sources {
+		if err := next(i); err != nil {
+			return false, err
+		}
+	}
+
+	before := mc.written
+	for {
+		pick := -1
+		for i, h := range heads {
+			if h.ok && (pick == -1 || h.t.Before(heads[pick].t)) {
+				pick = i
+			}
+		}
+		if pick == -1 {
+			break
+		}
+		mc.appendEntry(mc.sources[pick].tag, heads[pick].entry)
+		if len(mc.out) >= mergeFlushSize {
+			if err := mc.flush(); err != nil {
+				return false, err
+			}
+		}
+		if err := next(pick); err != nil {
+			return false, err
+		}
+	}
+	if err := mc.flush(); err != nil {
+		return false, err
+	}
+	return mc.written != before, nil
+}
+
+// Reads the next entry: a line, along with any following lines that don't
+// have a timestamp. The entry's time is that of its first line (or of the
+// last timestamped line, if it doesn't have one).
+func (src *mergeSource) readEntry(layouts []timeLayout, now time.Time) (string, time.Time, bool, error) {
+	line := src.peeked
+	src.peeked = ""
+	if line == "" {
+		var err error
+		line, err = src.reader.ReadLine()
+		if err == io.EOF {
+			return "", time.Time{}, false, nil
+		}
+		if err != nil {
+			return "", time.Time{}, false, err
+		}
+	}
+	if t, ok := src.hint.lineTime(line, layouts, now); ok {
+		src.last = t
+	}
+	entry := line
+	for {
+		line, err := src.reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", time.Time{}, false, err
+		}
+		if _, ok := src.hint.lineTime(line, layouts, now); ok {
+			src.peeked = line
+			break
+		}
+		entry += line
+	}
+	src.offset += len(entry)
+	return entry, src.last, true, nil
+}
+
+// Adds an entry to the output, with each of its lines tagged.
+func (mc *MergedContent) appendEntry(tag, entry string) {
+	for start := 0; start < len(entry); {
+		end := start + 1
+		for entry[end-1] != '\n' {
+			end++
+		}
+		mc.out = append(mc.out, tag...)
+		mc.out = append(mc.out, entry[start:end]...)
+		start = end
+	}
+}
+
+// Writes the buffered output to the spill file, making it visible.
+func (mc *MergedContent) flush() error {
+	if len(mc.out) == 0 {
+		return nil
+	}
+	if _, err := mc.spill.WriteAt(mc.out, int64(mc.written)); err != nil {
+		return err
+	}
+	mc.written += len(mc.out)
+	mc.out = mc.out[:0]
+	mc.mu.Lock()
+	mc.size = int64(mc.written)
+	mc.mu.Unlock()
+	return nil
+}
+
+func (mc *MergedContent) Size() (int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.size, mc.err
+}
+
+func (mc *MergedContent) ReadAt(p []byte, off int64) (int, error) {
+	size, _ := mc.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	if rem := size - off; int64(len(p)) > rem {
+		n, err := mc.spill.ReadAt(p[:rem], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return mc.spill.ReadAt(p, off)
+}
+
+func (mc *MergedContent) Write([]byte) {
+	panic("should not be called")
+}
+
+// Closes the merged contents and the spill file.
+func (mc *MergedContent) Close() error {
+	mc.closeSources()
+	return mc.spill.Close()
+}
+
+func (mc *MergedContent) closeSources() {
+	for _, src := range mc.sources {
+		closeContents(src.content)
+	}
+}
+
+func (mc *MergedContent) TagStyle(line string) (int, Style) {
+	for i, src := range mc.sources {
+		if len(line) >= len(src.tag) && line[:len(src.tag)] == src.tag {
+			return len(src.tag), MixStyle(mergeTagColours[i%len(mergeTagColours)], Default)
+		}
+	}
+	return 0, Style{}
+}
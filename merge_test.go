@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergedContent(t *testing.T) {
+	a := NewBufferContent()
+	a.Write([]byte(strings.Join([]string{
+		"preamble\n",
+		"2024-05-01T10:00:00Z a0\n",
+		"2024-05-01T10:00:02Z a2\n",
+		"  at frame\n",
+		"2024-05-01T10:00:04Z a4\n",
+		"2024-05-01T10:00:06Z partial",
+	}, "")))
+	b := NewBufferContent()
+	b.Write([]byte(strings.Join([]string{
+		"2024-05-01T10:00:01Z b1\n",
+		"2024-05-01T10:00:02Z b2\n",
+		"2024-05-01T10:00:05Z b5\n",
+	}, "")))
+
+	layouts, err := parseTimeLayouts(defaultTimeLayouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newMergedContent([]Content{a, b}, []string{"logs/a.log", "other/bb.log"}, layouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := func() []string {
+		size, err := mc.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, size)
+		if _, err := mc.ReadAt(buf, 0); err != nil {
+			t.Fatal(err)
+		}
+		return strings.SplitAfter(string(buf), "\n")
+	}
+
+	if _, err := mc.mergeAvailable(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a.log  | preamble\n",
+		"a.log  | 2024-05-01T10:00:00Z a0\n",
+		"bb.log | 2024-05-01T10:00:01Z b1\n",
+		"a.log  | 2024-05-01T10:00:02Z a2\n",
+		"a.log  |   at frame\n",
+		"bb.log | 2024-05-01T10:00:02Z b2\n",
+		"a.log  | 2024-05-01T10:00:04Z a4\n",
+		"bb.log | 2024-05-01T10:00:05Z b5\n",
+		"",
+	}
+	if got := lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Lines appended to the sources are merged with each other, after the
+	// lines that were already merged.
+	a.Write([]byte("\n2024-05-01T10:00:08Z a8\n"))
+	b.Write([]byte("2024-05-01T10:00:07Z b7\n"))
+	grew, err := mc.mergeAvailable()
+	if err != nil || !grew {
+		t.Fatalf("got grew=%t err=%v", grew, err)
+	}
+	want = append(want[:len(want)-1],
+		"a.log  | 2024-05-01T10:00:06Z partial\n",
+		"bb.log | 2024-05-01T10:00:07Z b7\n",
+		"a.log  | 2024-05-01T10:00:08Z a8\n",
+		"",
+	)
+	if got := lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	if grew, err := mc.mergeAvailable(); err != nil || grew {
+		t.Fatalf("got grew=%t err=%v, want no change", grew, err)
+	}
+
+	if n, style := mc.TagStyle(want[2]); n != len("bb.log | ") || style != MixStyle(mergeTagColours[1], Default) {
+		t.Errorf("got tag style %d %v", n, style)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	for _, test := range []struct {
+		filenames []string
+		want      []string
+	}{
+		{[]string{"x/svc1.log", "y/svc22.log"}, []string{"svc1.log  | ", "svc22.log | "}},
+		{[]string{"x/app.log", "y/app.log"}, []string{"x/app.log | ", "y/app.log | "}},
+	} {
+		if got := mergeTags(test.filenames); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("filenames=%q: got %q, want %q", test.filenames, got, test.want)
+		}
+	}
+}
+
+// Content that's reset (as if rotated) the next time it's checked.
+type resettingContent struct {
+	*BufferContent
+	reset bool
+}
+
+func (r *resettingContent) CheckReopen(int64) (string, error) {
+	if !r.reset {
+		return "", nil
+	}
+	r.reset = false
+	return "file was replaced (rotated), reopened from the start", nil
+}
+
+func TestMergedContentRotation(t *testing.T) {
+	a := &resettingContent{BufferContent: NewBufferContent()}
+	a.Write([]byte("2024-05-01T10:00:00Z old\n"))
+	layouts, err := parseTimeLayouts(defaultTimeLayouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newMergedContent([]Content{a}, []string{"a.log"}, layouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.mergeAvailable(); err != nil {
+		t.Fatal(err)
+	}
+
+	a.BufferContent = NewBufferContent()
+	a.Write([]byte("2024-05-01T10:00:01Z new\n"))
+	a.reset = true
+	if _, err := mc.mergeAvailable(); err != nil {
+		t.Fatal(err)
+	}
+
+	size, _ := mc.Size()
+	buf := make([]byte, size)
+	if _, err := mc.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.log | 2024-05-01T10:00:00Z old\n" +
+		"a.log | --- file was replaced (rotated), reopened from the start ---\n" +
+		"a.log | 2024-05-01T10:00:01Z new\n"
+	if got := string(buf); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
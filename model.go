@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,12 +26,33 @@ type Model struct {
 
 	fileSize int
 
+	// A restored offset that's waiting for the content to grow past it (0 if
+	// there isn't one).
+	pendingOffset int
+
 	tmpRegex *regexp.Regexp
 	regexes  []regex
 
 	lineWrapMode bool
 	xPosition    int
 
+	ansiColours bool
+	prettyJSON  bool
+
+	columnMode    bool
+	hiddenColumns map[string]bool
+	columnOrder   []string // Columns shown first, in order.
+
+	following bool
+
+	index        *LineIndex
+	indexing     bool
+	indexPending bool
+	cancelIndex  *Cancellable
+
+	showLineNumbers bool
+	lineNums        map[int]int // Line numbers of displayed lines, keyed by offset.
+
 	msg      string
 	msgSetAt time.Time
 
@@ -40,12 +62,43 @@ type Model struct {
 	cycle int
 
 	longFileOpInProgress bool
-	cancelLongFileOp     Cancellable
+	cancelLongFileOp     *Cancellable
+	longFileOpDesc       string // Shown while the op runs, if not a search.
+	searchProgress       searchProgress
+	searchResume         *searchResume
+
+	matchCount *matchCount
+	incSearch  *incSearch
+
+	searchModes searchModes
+
+	fillingScreenBuffer bool
+	tailInProgress      bool
+	tailPending         bool
+
+	// Incremented each time the loaded lines are discarded (e.g. because
+	// the content was reset, or the filters changed). Lines from loads that
+	// were in flight at the time are discarded too.
+	loadGen int
+
+	fwdExhausted exhausted
+	bckExhausted exhausted
+
+	filters        filters
+	filtersEnabled bool
+
+	substitutions substitutions
 
 	history    map[CommandMode][]string // most recent is first in list
 	historyIdx int                      // -1 when history not used
 
+	jumps   []int // Offsets jumped away from, oldest first.
+	jumpIdx int   // Position in jumps, len(jumps) when not navigating.
+
+	marks map[byte]mark
+
 	showHelp bool
+	timeline *timeline // Shown when non-nil.
 }
 
 type Command struct {
@@ -63,6 +116,11 @@ const (
 	SeekCommand
 	BisectCommand
 	QuitCommand
+	ExCommand
+	FilterCommand
+	MarkCommand
+	GotoMarkCommand
+	TimeCommand
 )
 
 type regex struct {
@@ -70,6 +128,23 @@ type regex struct {
 	re    *regexp.Regexp
 }
 
+// Records that loading from an offset found no further (visible) lines when
+// the file was a particular size. The load isn't retried until something
+// changes.
+type exhausted struct {
+	offset   int
+	fileSize int
+}
+
+var notExhausted = exhausted{-1, -1}
+
+// A named bookmark. Offsets stay valid as the file grows, so marks only need
+// to be discarded when the content is reset.
+type mark struct {
+	offset  int
+	preview string // The marked line, as it was when marked.
+}
+
 type line struct {
 	offset int
 	data   string
@@ -83,6 +158,10 @@ func (m *Model) StartCommandMode(mode CommandMode) {
 	m.cmd.Mode = mode
 	m.msg = ""
 	m.historyIdx = -1
+	if mode == SearchCommand {
+		m.cancelIncSearch()
+		m.incSearch = &incSearch{origin: m.offset}
+	}
 }
 
 func (m *Model) ExitCommandMode() {
@@ -132,12 +211,19 @@ func (m *Model) ForwardInHistory() {
 func (m *Model) Interrupt() {
 	log.Info("Caught interrupt.")
 	if m.cmd.Mode != NoCommand {
+		if m.cmd.Mode == SearchCommand {
+			m.abandonIncSearch()
+		}
 		m.cmd.Mode = NoCommand
 		m.cmd.Text = ""
 		m.cmd.Pos = 0
+	} else if m.incSearch != nil {
+		m.cancelIncSearch()
 	} else if m.longFileOpInProgress {
 		m.cancelLongFileOp.Cancel()
 		m.longFileOpInProgress = false
+	} else if m.timeline != nil {
+		m.timeline = nil
 	} else {
 		m.StartCommandMode(QuitCommand)
 	}
@@ -158,14 +244,14 @@ func (m *Model) moveToOffset(offset int) {
 
 	for _, ln := range *ahead {
 		if ln.offset == offset {
+			// Lines may be hidden by filters, so the offset is taken
+			// from the line now at the front (not the end of the line
+			// moved past).
 			for m.offset != offset {
 				l := (*ahead)[0]
 				*ahead = (*ahead)[1:]
 				*aback = append([]line{l}, *aback...)
-				m.offset = l.offset
-				if ahead == &m.fwd {
-					m.offset += len(l.data)
-				}
+				m.offset = m.fwd[0].offset
 			}
 			return
 		}
@@ -175,6 +261,132 @@ func (m *Model) moveToOffset(offset int) {
 	m.offset = offset
 }
 
+const maxJumps = 100
+
+// Moves to an offset, recording the current offset in the jump list. Used for
+// large movements (rather than scrolling).
+func (m *Model) jumpToOffset(offset int) {
+	if offset != m.offset {
+		m.recordJump(m.offset)
+	}
+	m.moveToOffset(offset)
+}
+
+func (m *Model) recordJump(from int) {
+	m.jumps = m.jumps[:m.jumpIdx]
+	if len(m.jumps) == 0 || m.jumps[len(m.jumps)-1] != from {
+		m.jumps = append(m.jumps, from)
+	}
+	if len(m.jumps) > maxJumps {
+		m.jumps = m.jumps[len(m.jumps)-maxJumps:]
+	}
+	m.jumpIdx = len(m.jumps)
+}
+
+func (m *Model) jumpBack() {
+	if m.jumpIdx == 0 {
+		m.setMessage("already at oldest jump")
+		return
+	}
+	if m.jumpIdx == len(m.jumps) {
+		// Remember where we are, so that we can come forward again.
+		m.recordJump(m.offset)
+		m.jumpIdx = len(m.jumps) - 1
+	}
+	if m.jumpIdx <= 0 {
+		// The only jump was to here.
+		m.setMessage("already at oldest jump")
+		return
+	}
+	m.pauseFollowing()
+	m.jumpIdx--
+	m.moveToOffset(m.jumps[m.jumpIdx])
+}
+
+func (m *Model) jumpForward() {
+	if m.jumpIdx+1 >= len(m.jumps) {
+		m.setMessage("already at newest jump")
+		return
+	}
+	m.pauseFollowing()
+	m.jumpIdx++
+	m.moveToOffset(m.jumps[m.jumpIdx])
+}
+
+func isMarkName(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// Handles the single key that follows the set mark or goto mark keys.
+func (m *Model) markKeyPressed(k Key) {
+	mode := m.cmd.Mode
+	m.cmd.Mode = NoCommand
+	if len(k) != 1 {
+		return
+	}
+	name := k[0]
+	if mode == GotoMarkCommand && name == '\'' {
+		m.jumpBack()
+		return
+	}
+	if !isMarkName(name) {
+		m.setMessage(fmt.Sprintf("invalid mark name (should be a letter): %v", k))
+		return
+	}
+	switch mode {
+	case MarkCommand:
+		m.setMark(name)
+	case GotoMarkCommand:
+		m.gotoMark(name)
+	default:
+		assert(false)
+	}
+}
+
+func (m *Model) setMark(name byte) {
+	mk := mark{offset: m.offset}
+	if len(m.fwd) > 0 {
+		mk.preview = strings.TrimSuffix(m.fwd[0].data, "\n")
+	}
+	log.Info("Setting mark: name=%c offset=%d", name, mk.offset)
+	if m.marks == nil {
+		m.marks = map[byte]mark{}
+	}
+	m.marks[name] = mk
+	m.setMessage(fmt.Sprintf("set mark '%c'", name))
+}
+
+func (m *Model) gotoMark(name byte) {
+	mk, ok := m.marks[name]
+	if !ok {
+		m.setMessage(fmt.Sprintf("mark not set: '%c'", name))
+		return
+	}
+	log.Info("Jumping to mark: name=%c offset=%d", name, mk.offset)
+	m.pauseFollowing()
+	m.jumpToOffset(mk.offset)
+}
+
+// Gives the name of the mark at an offset, or 0 if there isn't one.
+func (m *Model) markAt(offset int) byte {
+	var found byte
+	for name, mk := range m.marks {
+		if mk.offset == offset && (found == 0 || name < found) {
+			found = name
+		}
+	}
+	return found
+}
+
+func (m *Model) markNames() []byte {
+	var names []byte
+	for name := range m.marks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
 func (m *Model) moveDown() {
 	log.Info("Moving down.")
 	if len(m.fwd) < 2 {
@@ -186,6 +398,7 @@ func (m *Model) moveDown() {
 
 func (m *Model) moveUp() {
 	log.Info("Moving up.")
+	m.pauseFollowing()
 	if m.offset == 0 {
 		log.Info("Cannot move back: at start of file.")
 		return
@@ -199,7 +412,8 @@ func (m *Model) moveUp() {
 
 func (m *Model) moveTop() {
 	log.Info("Jumping to start of file.")
-	m.moveToOffset(0)
+	m.pauseFollowing()
+	m.jumpToOffset(0)
 }
 
 func (m *Model) moveDownByHalfScreen() {
@@ -224,6 +438,144 @@ func (m *Model) toggleLineWrapMode() {
 	m.xPosition = 0
 }
 
+func (m *Model) toggleAnsiColours() {
+	m.ansiColours = !m.ansiColours
+	if m.ansiColours {
+		m.setMessage("rendering ANSI colours")
+	} else {
+		m.setMessage("showing raw ANSI escape sequences")
+	}
+}
+
+func (m *Model) togglePrettyJSON() {
+	m.prettyJSON = !m.prettyJSON
+	if m.prettyJSON {
+		m.setMessage("pretty printing JSON lines (expanded in line wrap mode)")
+	} else {
+		m.setMessage("showing JSON lines as-is")
+	}
+}
+
+// Gives how JSON log entries are displayed.
+func (m *Model) jsonDisplay() jsonDisplay {
+	switch {
+	case !m.prettyJSON:
+		return jsonRaw
+	case m.lineWrapMode:
+		return jsonExpanded
+	default:
+		return jsonCompact
+	}
+}
+
+func (m *Model) toggleFollowing() {
+	if m.following {
+		log.Info("Toggling out of follow mode.")
+	} else {
+		log.Info("Toggling into follow mode.")
+	}
+	m.following = !m.following
+}
+
+func (m *Model) pauseFollowing() {
+	if m.following {
+		log.Info("Pausing follow mode.")
+		m.following = false
+		m.setMessage("follow mode paused")
+	}
+}
+
+func (m *Model) toggleLineNumbers() {
+	m.showLineNumbers = !m.showLineNumbers
+}
+
+// Gives the number of columns used to display marks and line numbers
+// (including the space separating them from the line).
+func (m *Model) gutterWidth() int {
+	width := m.markGutterWidth()
+	if m.showLineNumbers {
+		lines, _ := m.index.Lines()
+		width += len(strconv.Itoa(max(lines, 1))) + 1
+	}
+	if width*2 > m.cols {
+		return 0
+	}
+	return width
+}
+
+// Gives the number of columns at the start of the gutter used to display mark
+// names. They're only shown once at least one mark has been set.
+func (m *Model) markGutterWidth() int {
+	if len(m.marks) == 0 {
+		return 0
+	}
+	return 2
+}
+
+// Finds the line numbers of the displayed lines (for those that have been
+// reached by the line index).
+func (m *Model) updateLineNumbers() {
+	const maxCached = 1 << 12
+	if len(m.lineNums) > maxCached {
+		m.lineNums = map[int]int{}
+	}
+	offsets := []int{m.offset}
+	for i := 0; i < len(m.fwd) && i < m.rows; i++ {
+		offsets = append(offsets, m.fwd[i].offset)
+	}
+	for _, offset := range offsets {
+		if _, ok := m.lineNums[offset]; ok {
+			continue
+		}
+		num, ok, err := m.index.LineNumber(m.content, offset)
+		if err != nil {
+			log.Warn("Could not find line number: offset=%d err=%v", offset, err)
+			return
+		}
+		if !ok {
+			return // Index hasn't reached this far yet.
+		}
+		m.lineNums[offset] = num
+	}
+}
+
+// Gives the (zero based) line number of the line at an offset, if known.
+func (m *Model) lineNumber(offset int) (int, bool) {
+	num, ok := m.lineNums[offset]
+	return num, ok
+}
+
+// Gives a func that returns the number of screen rows that a line occupies.
+// The func is safe to call outside of the reactor.
+func (m *Model) rowsForLine() func(string) int {
+	if !m.lineWrapMode || m.columnMode {
+		v := m.lineView()
+		return func(data string) int {
+			if !v.allow(data) {
+				return 0
+			}
+			return 1
+		}
+	}
+	cols := m.cols - m.gutterWidth()
+	var prefixLen int
+	if len(m.config.WrapPrefix)+1 < cols {
+		prefixLen = len(m.config.WrapPrefix)
+	}
+	v := m.lineView()
+	ansiColours := m.ansiColours
+	tabWidth := m.config.TabWidth
+	json := m.jsonDisplay()
+	return func(data string) int {
+		if !v.allow(data) {
+			return 0
+		}
+		text, base := displayText(data, ansiColours, v.subs, json)
+		cells, _ := renderLine(text, base, tabWidth)
+		return wrapRows(cells, cols, cols-prefixLen)
+	}
+}
+
 func (m *Model) currentRE() *regexp.Regexp {
 	re := m.tmpRegex
 	if re == nil && len(m.regexes) > 0 {
@@ -237,14 +589,35 @@ func (m *Model) setMessage(msg string) {
 	m.msgSetAt = time.Now()
 }
 
+// Scrolls left by a quarter of the screen, or by a column in column mode.
 func (m *Model) reduceXPosition() {
+	if m.columnMode {
+		m.changeXPosition(max(0, m.xPosition-1))
+		return
+	}
 	m.changeXPosition(max(0, m.xPosition-m.cols/4))
 }
 
+// Scrolls right by a quarter of the screen, or by a column in column mode
+// (stopping at the last column).
 func (m *Model) increaseXPosition() {
+	if m.columnMode {
+		layout, _ := m.screenColumns()
+		m.changeXPosition(clamp(m.xPosition+1, 0, max(0, len(layout.names)-1)))
+		return
+	}
 	m.changeXPosition(max(0, m.xPosition+m.cols/4))
 }
 
+// Gives the number of screen rows available for displaying lines.
+func (m *Model) lineRows() int {
+	rows := m.rows - 2 // Status line and command line.
+	if m.columnMode {
+		rows-- // Column headers.
+	}
+	return rows
+}
+
 func (m *Model) changeXPosition(newPosition int) {
 	log.Info("Changing x position: old=%v new=%v", m.xPosition, newPosition)
 	if m.xPosition != newPosition {
@@ -305,29 +678,218 @@ func (m *Model) FileSize(size int) {
 	}
 }
 
-func (m *Model) searchEntered(cmd string) {
-	re, err := regexp.Compile(cmd)
+// ContentReset is used when the content has been replaced or truncated. Any
+// loaded lines (and any file op in progress) refer to data that no longer
+// exists, so everything is discarded and the view restarts from the top.
+func (m *Model) ContentReset(reason string) {
+	log.Info("Content reset: reason=%q", reason)
+	if m.longFileOpInProgress {
+		m.cancelLongFileOp.Cancel()
+		m.longFileOpInProgress = false
+	}
+	m.offset = 0
+	m.pendingOffset = 0
+	m.discardLoaded()
+	m.jumps = nil
+	m.jumpIdx = 0
+	m.marks = nil
+	m.timeline = nil
+	m.resetMatchCount()
+	m.cancelIncSearch()
+	m.fileSize = 0
+	m.resetIndex()
+	m.setMessage(reason)
+}
+
+func (m *Model) resetIndex() {
+	if m.cancelIndex != nil {
+		m.cancelIndex.Cancel()
+	}
+	m.index = NewLineIndex()
+	m.indexing = false
+	m.indexPending = false
+	m.lineNums = map[int]int{}
+}
+
+// Discards all loaded lines, so that they're loaded again from scratch.
+func (m *Model) discardLoaded() {
+	m.fwd = nil
+	m.bck = nil
+	m.loadGen++
+	m.fwdExhausted = notExhausted
+	m.bckExhausted = notExhausted
+}
+
+// Captures the substitutions and (enabled) filters, for use outside of the
+// reactor.
+func (m *Model) lineView() lineView {
+	v := lineView{subs: append(substitutions(nil), m.substitutions...)}
+	if m.filtersEnabled {
+		v.filters = append(filters(nil), m.filters...)
+	}
+	return v
+}
+
+func (m *Model) filterEntered(cmd string) {
+	if cmd == "" {
+		if len(m.filters) == 0 {
+			m.setMessage("no filters to remove")
+			return
+		}
+		removed := m.filters[len(m.filters)-1]
+		m.filters = m.filters[:len(m.filters)-1]
+		m.setMessage(fmt.Sprintf("removed filter: %v", removed))
+	} else {
+		f, err := parseFilter(cmd)
+		if err != nil {
+			m.setMessage(err.Error())
+			return
+		}
+		m.filters = append(m.filters, f)
+		m.filtersEnabled = true
+	}
+	m.discardLoaded()
+}
+
+func (m *Model) toggleFilters() {
+	if len(m.filters) == 0 {
+		m.setMessage("no filters to toggle")
+		return
+	}
+	m.filtersEnabled = !m.filtersEnabled
+	m.discardLoaded()
+}
+
+func (m *Model) exEntered(cmd string) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+	if num, err := strconv.Atoi(cmd); err == nil {
+		m.gotoLine(num)
+		return
+	}
+	switch fields := strings.Fields(cmd); {
+	case cmd == "s":
+		m.listSubstitutions()
+	case len(cmd) > 1 && cmd[0] == 's' && isSubstituteDelim(cmd[1]):
+		m.addSubstitution(cmd)
+	case fields[0] == "unsub" && len(fields) <= 2:
+		m.deleteSubstitution(fields[1:])
+	case cmd == "cols":
+		m.listColumns()
+	case fields[0] == "hide":
+		m.hideColumns(fields[1:])
+	case fields[0] == "show":
+		m.showColumns(fields[1:])
+	case fields[0] == "order":
+		m.orderColumns(fields[1:])
+	default:
+		m.setMessage(fmt.Sprintf("unknown command: %v", cmd))
+	}
+}
+
+func (m *Model) addSubstitution(cmd string) {
+	s, err := parseSubstitution(cmd)
 	if err != nil {
 		m.setMessage(err.Error())
 		return
 	}
-	m.tmpRegex = re
+	log.Info("Adding substitution: %v", s)
+	m.substitutions = append(m.substitutions, s)
+	m.discardLoaded()
 }
 
-func (m *Model) colourEntered(cmd string) {
-	err := fmt.Errorf("colour code must be in format [0-8][0-8]: %v", cmd)
-	if len(cmd) != 2 {
+func (m *Model) listSubstitutions() {
+	if len(m.substitutions) == 0 {
+		m.setMessage("no substitutions")
+		return
+	}
+	var list []string
+	for i, s := range m.substitutions {
+		list = append(list, fmt.Sprintf("%d:%v", i+1, s))
+	}
+	m.setMessage("substitutions: " + strings.Join(list, " "))
+}
+
+// Deletes the substitution with the given (one based) number, or the most
+// recently added one if there's no number.
+func (m *Model) deleteSubstitution(args []string) {
+	if len(m.substitutions) == 0 {
+		m.setMessage("no substitutions to remove")
+		return
+	}
+	idx := len(m.substitutions) - 1
+	if len(args) == 1 {
+		num, err := strconv.Atoi(args[0])
+		if err != nil || num < 1 || num > len(m.substitutions) {
+			m.setMessage(fmt.Sprintf("invalid substitution number (should be 1 to %d): %v", len(m.substitutions), args[0]))
+			return
+		}
+		idx = num - 1
+	}
+	removed := m.substitutions[idx]
+	m.substitutions = append(m.substitutions[:idx], m.substitutions[idx+1:]...)
+	m.setMessage(fmt.Sprintf("removed substitution: %v", removed))
+	m.discardLoaded()
+}
+
+func (m *Model) gotoLine(num int) {
+	if num < 1 {
+		m.setMessage(fmt.Sprintf("invalid line number: %d", num))
+		return
+	}
+	offset, ok, err := m.index.LineOffset(m.content, num-1)
+	if err != nil {
+		log.Warn("Could not find line offset: num=%d err=%v", num, err)
 		m.setMessage(err.Error())
 		return
 	}
-	fg := cmd[0]
-	bg := cmd[1]
-	if fg < '0' || fg > '8' || bg < '0' || bg > '8' {
+	if !ok {
+		lines, indexed := m.index.Lines()
+		if indexed < m.fileSize {
+			m.setMessage(fmt.Sprintf("line %d not indexed yet (%d lines indexed so far)", num, lines))
+		} else {
+			m.setMessage(fmt.Sprintf("line %d is past the end of the file (%d lines)", num, lines))
+		}
+		return
+	}
+	m.pauseFollowing()
+	m.jumpToOffset(offset)
+}
+
+func (m *Model) searchEntered(cmd string) {
+	re, err := compileSearch(cmd, m.searchModes)
+	if err != nil {
+		m.setMessage(err.Error())
+		return
+	}
+	m.tmpRegex = re
+}
+
+// Toggles a search mode, if the key is for one. The modes stay in effect for
+// later searches.
+func (m *Model) toggleSearchMode(k Key) bool {
+	switch k {
+	case CtrlT:
+		m.searchModes.smartCase = !m.searchModes.smartCase
+	case CtrlR:
+		m.searchModes.literal = !m.searchModes.literal
+	case CtrlW:
+		m.searchModes.wholeWord = !m.searchModes.wholeWord
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *Model) colourEntered(cmd string) {
+	style, err := parseStyle(cmd)
+	if err != nil {
 		m.setMessage(err.Error())
 		return
 	}
 
-	style := MixStyle(styles[fg-'0'], styles[bg-'0'])
 	if m.tmpRegex != nil {
 		m.regexes = append([]regex{{style, m.tmpRegex}}, m.regexes...)
 		m.tmpRegex = nil
@@ -350,50 +912,14 @@ func (m *Model) seekEntered(cmd string) error {
 		return nil
 	}
 
+	m.pauseFollowing()
 	offset, err := FindSeekOffset(m.content, seekPct)
 	if err != nil {
 		log.Warn("Could to find start of line at offset: %v", err)
 		return err
 	}
 
-	m.moveToOffset(offset)
-	return nil
-}
-
-func (m *Model) bisectEntered(cmd string) error {
-	sz, err := m.content.Size()
-	if err != nil {
-		return err
-	}
-
-	var start int
-	end := int(sz - 1)
-
-	var i int
-	for {
-		i++
-		if i == 1000 {
-			m.setMessage("could not find bisect target after 1000 iterations")
-			return nil
-		}
-
-		offset := start + rand.Intn(end-start+1)
-		line, offset, err := lineAt(m.content, offset)
-		if err != nil {
-			return err
-		}
-		if start+len(line) >= end {
-			break
-		}
-		if m.config.BisectMask.MatchString(transform(line)) {
-			if cmd < string(line) {
-				end = offset
-			} else {
-				start = offset
-			}
-		}
-	}
-	m.moveToOffset(start)
+	m.jumpToOffset(offset)
 	return nil
 }
 
@@ -404,6 +930,13 @@ func (m *Model) needsLoadingForward() int {
 	if len(m.fwd) >= m.rows*forwardLoadFactor {
 		return 0
 	}
+	next := m.offset
+	if len(m.fwd) > 0 {
+		next = m.fwd[len(m.fwd)-1].nextOffset()
+	}
+	if m.fwdExhausted == (exhausted{next, m.fileSize}) {
+		return 0
+	}
 	if len(m.fwd) > 0 {
 		lastLine := m.fwd[len(m.fwd)-1]
 		if lastLine.offset+len(lastLine.data) >= m.fileSize {
@@ -420,6 +953,13 @@ func (m *Model) needsLoadingBackward() int {
 	if len(m.bck) >= m.rows*backLoadFactor {
 		return 0
 	}
+	next := m.offset
+	if len(m.bck) > 0 {
+		next = m.bck[len(m.bck)-1].offset
+	}
+	if m.bckExhausted.offset == next {
+		return 0
+	}
 	if len(m.bck) > 0 {
 		lastLine := m.bck[len(m.bck)-1]
 		if lastLine.offset == 0 {
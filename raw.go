@@ -29,7 +29,9 @@ func enterRaw() ttyState {
 		os.Exit(1)
 	}
 
-	cmd = exec.Command("stty", "cbreak", "-echo")
+	// Without -iexten, some tty drivers (e.g. on macOS and BSD) swallow keys
+	// such as Ctrl-O (discard) and Ctrl-V (literal next) themselves.
+	cmd = exec.Command("stty", "cbreak", "-echo", "-iexten")
 	cmd.Stdin = tty
 	combinedOut, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1,7 +1,7 @@
 package main
 
 type ScreenState struct {
-	Chars  []byte
+	Chars  []rune
 	Styles []Style
 	Cols   int
 	ColPos int // Always on last row.
@@ -10,7 +10,7 @@ type ScreenState struct {
 func NewScreenState(rows, cols int) ScreenState {
 	s := ScreenState{Cols: cols, ColPos: cols - 1}
 	n := rows * cols
-	s.Chars = make([]byte, n)
+	s.Chars = make([]rune, n)
 	s.Styles = make([]Style, n)
 	return s
 }
@@ -18,7 +18,7 @@ func NewScreenState(rows, cols int) ScreenState {
 func (s ScreenState) Init() {
 	for i := range s.Chars {
 		s.Chars[i] = ' '
-		s.Styles[i] = 0
+		s.Styles[i] = Style{}
 	}
 }
 
@@ -32,7 +32,7 @@ func (s ScreenState) RowColIdx(row, col int) int {
 
 func (s ScreenState) CloneInto(into *ScreenState) {
 	if len(s.Chars) != len(into.Chars) {
-		into.Chars = make([]byte, len(s.Chars))
+		into.Chars = make([]rune, len(s.Chars))
 		into.Styles = make([]Style, len(s.Styles))
 	}
 	assert(len(s.Styles) == len(into.Styles))
@@ -41,3 +41,16 @@ func (s ScreenState) CloneInto(into *ScreenState) {
 	copy(into.Chars, s.Chars)
 	copy(into.Styles, s.Styles)
 }
+
+// Copies the runes of a string into cells, returning the number copied.
+func copyString(cells []rune, str string) int {
+	var n int
+	for _, r := range str {
+		if n == len(cells) {
+			break
+		}
+		cells[n] = r
+		n++
+	}
+	return n
+}
@@ -73,12 +73,17 @@ func ScreenDiff(from, to ScreenState) *bytes.Buffer {
 			fmt.Fprintf(buf, "\x1b[%d;H", row+1)
 			for col := 0; col <= firstMismatchCol; col++ {
 				idx := to.RowColIdx(row, col)
-				if !writtenStyle || currentStyle != to.Styles[idx] {
+				if to.Chars[idx] == continuationCell {
+					continue // Already covered by the double width char.
+				}
+				if !writtenStyle {
 					buf.WriteString(to.Styles[idx].escapeCode())
 					writtenStyle = true
-					currentStyle = to.Styles[idx]
+				} else {
+					buf.WriteString(to.Styles[idx].transitionFrom(currentStyle))
 				}
-				buf.WriteByte(to.Chars[idx])
+				currentStyle = to.Styles[idx]
+				buf.WriteRune(to.Chars[idx])
 			}
 		}
 	}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const searchProgressInterval = 200 * time.Millisecond
+
+// Progress of a regex search, reported back to the reactor periodically while
+// the search runs.
+type searchProgress struct {
+	offset  int // Offset that the search has reached.
+	scanned int // Number of bytes scanned.
+	started time.Time
+	resumed bool // The search picked up from where a cancelled one stopped.
+}
+
+func (p searchProgress) describe(fileSize int) string {
+	var pct float64
+	if fileSize > 0 {
+		pct = float64(p.offset) / float64(fileSize) * 100
+	}
+	var rate float64
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		rate = float64(p.scanned) / elapsed / 1e6
+	}
+	verb := "searching"
+	if p.resumed {
+		verb = "resumed search"
+	}
+	return fmt.Sprintf("%s: offset %d (%.1f%%), %.1f MB/s (interrupt to cancel)", verb, p.offset, pct, rate)
+}
+
+// Records where a cancelled search stopped, so that repeating the same search
+// from the same place can pick up from there rather than rescanning.
+type searchResume struct {
+	re      string
+	reverse bool
+	origin  int // Offset of the top line when the search was started.
+	loadGen int // Loaded lines generation, which changes with filters and substitutions.
+	from    int // Offset that the search started from.
+	offset  int // Offset to resume the search from.
+	wrapped bool
+}
+
+func (r *searchResume) matches(re string, reverse bool, origin, loadGen int) bool {
+	return r != nil && r.re == re && r.reverse == reverse && r.origin == origin && r.loadGen == loadGen
+}
+
+const searchChunkSize = 1 << 22
+
+// A search for the first line (or the last line, when searching in reverse)
+// matching a regex. Only lines starting in [lo, hi) are considered. The range
+// is split into chunks, which are scanned concurrently.
+type search struct {
+	content   Content
+	re        *regexp.Regexp
+	v         lineView
+	reverse   bool
+	lo, hi    int
+	size      int // Lines that aren't terminated by size are ignored.
+	chunkSize int
+	literal   []byte // Must appear in any matching line (if not empty).
+	cancel    *Cancellable
+}
+
+type chunkResult struct {
+	idx    int
+	offset int
+	found  bool
+	err    error
+}
+
+// Finds the offset of the matching line. If the search is stopped early (by
+// cancellation), reached gives the offset that the search got up to, from
+// which it could be resumed. Progress is reported as the search proceeds.
+func (s *search) run(progress func(reached, scanned int)) (offset int, found bool, reached int, err error) {
+	if s.chunkSize == 0 {
+		s.chunkSize = searchChunkSize
+	}
+	if s.literal == nil && len(s.v.subs) == 0 {
+		s.literal = requiredLiteral(s.re)
+	}
+	numChunks := (s.hi - s.lo + s.chunkSize - 1) / s.chunkSize
+	if numChunks <= 0 {
+		return 0, false, s.boundary(0), nil
+	}
+
+	// Chunks are handed out in search order. Once a chunk has a match, there
+	// is no need to scan the chunks after it.
+	var next int64
+	stopAt := int64(numChunks)
+	results := make(chan chunkResult, numChunks)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), numChunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				idx := int(atomic.AddInt64(&next, 1) - 1)
+				if idx >= numChunks || idx > int(atomic.LoadInt64(&stopAt)) || s.cancel.Cancelled() {
+					return
+				}
+				offset, found, err := s.scanChunk(idx)
+				if found || err != nil {
+					for {
+						cur := atomic.LoadInt64(&stopAt)
+						if int64(idx) >= cur || atomic.CompareAndSwapInt64(&stopAt, cur, int64(idx)) {
+							break
+						}
+					}
+				}
+				results <- chunkResult{idx, offset, found, err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results are consumed in search order, so that the first match in that
+	// order is the one found.
+	done := make([]*chunkResult, numChunks)
+	var prefix, scanned int
+	for r := range results {
+		if s.cancel.Cancelled() {
+			// The chunk may not have been scanned completely.
+			break
+		}
+		r := r
+		done[r.idx] = &r
+		lo, hi := s.chunk(r.idx)
+		scanned += hi - lo
+		for prefix < numChunks && done[prefix] != nil {
+			if err := done[prefix].err; err != nil {
+				return 0, false, s.boundary(prefix), err
+			}
+			if done[prefix].found {
+				return done[prefix].offset, true, s.boundary(prefix), nil
+			}
+			prefix++
+		}
+		progress(s.boundary(prefix), scanned)
+	}
+	return 0, false, s.boundary(prefix), nil
+}
+
+// Gives the range of offsets covered by a chunk, where chunks are numbered in
+// search order.
+func (s *search) chunk(idx int) (int, int) {
+	if s.reverse {
+		return max(s.lo, s.hi-(idx+1)*s.chunkSize), s.hi - idx*s.chunkSize
+	}
+	return s.lo + idx*s.chunkSize, min(s.hi, s.lo+(idx+1)*s.chunkSize)
+}
+
+// Gives the offset that the search has reached once a number of chunks have
+// been scanned.
+func (s *search) boundary(chunks int) int {
+	if s.reverse {
+		return max(s.lo, s.hi-chunks*s.chunkSize)
+	}
+	return min(s.hi, s.lo+chunks*s.chunkSize)
+}
+
+// Scans the lines starting in a chunk, giving the first match (or last match,
+// in reverse).
+func (s *search) scanChunk(idx int) (int, bool, error) {
+	lo, hi := s.chunk(idx)
+
+	// Read from the byte before the chunk (to see if the chunk starts with a
+	// new line) until the end of the last line starting in the chunk.
+	readFrom := max(0, lo-1)
+	buf := make([]byte, hi-readFrom)
+	if _, err := s.content.ReadAt(buf, int64(readFrom)); err != nil && err != io.EOF {
+		return 0, false, err
+	}
+	for end := hi; buf[len(buf)-1] != '\n' && end < s.size; {
+		more := make([]byte, min(s.size-end, lineReaderReadSize))
+		n, err := s.content.ReadAt(more, int64(end))
+		if err != nil && (err != io.EOF || n == 0) {
+			return 0, false, err
+		}
+		if i := bytes.IndexByte(more[:n], '\n'); i >= 0 {
+			n = i + 1
+		}
+		buf = append(buf, more[:n]...)
+		end += n
+	}
+
+	// Find the first line starting in the chunk. The line straddling the
+	// start of the chunk belongs to the previous chunk.
+	pos := lo - readFrom
+	if lo != 0 && buf[0] != '\n' {
+		i := bytes.IndexByte(buf[pos:], '\n')
+		if i < 0 {
+			return 0, false, nil
+		}
+		pos += i + 1
+	}
+	end := hi - readFrom
+
+	literal := s.literal
+	if bytes.IndexByte(buf, '\b') >= 0 {
+		literal = nil // Overstrike is removed before matching.
+	}
+
+	var matchOffset int
+	var found bool
+	for pos < end {
+		if s.cancel.Cancelled() {
+			return 0, false, nil
+		}
+		if len(literal) > 0 {
+			// Skip straight to the line containing the next occurrence.
+			i := bytes.Index(buf[pos:], literal)
+			if i < 0 {
+				break
+			}
+			if nl := bytes.LastIndexByte(buf[pos:pos+i], '\n'); nl >= 0 {
+				pos += nl + 1
+			}
+			if pos >= end {
+				break
+			}
+		}
+		n := bytes.IndexByte(buf[pos:], '\n')
+		if n < 0 {
+			break // Not terminated.
+		}
+		data := string(buf[pos : pos+n+1])
+		if s.v.allow(data) && s.re.MatchString(s.v.text(data)) {
+			matchOffset = readFrom + pos
+			found = true
+			if !s.reverse {
+				break
+			}
+		}
+		pos += n + 1
+	}
+	return matchOffset, found, nil
+}
+
+// Finds a literal string that must appear in any text matched by a regex, so
+// that text without it can be skipped quickly. Gives nil if there's no such
+// literal.
+func requiredLiteral(re *regexp.Regexp) []byte {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	lit := requiredLiteralOf(parsed.Simplify())
+	if strings.ContainsRune(lit, utf8.RuneError) {
+		return nil // Could match invalid UTF-8.
+	}
+	return []byte(lit)
+}
+
+func requiredLiteralOf(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return ""
+		}
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteralOf(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiteralOf(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var longest string
+		for _, sub := range re.Sub {
+			if lit := requiredLiteralOf(sub); len(lit) > len(longest) {
+				longest = lit
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+// An incremental search, which moves the view to the first match of the
+// search regex as it's typed.
+type incSearch struct {
+	origin    int // Offset when the search command was started.
+	cancel    *Cancellable
+	running   bool
+	committed bool // The search command was entered while still running.
+}
+
+// Starts searching for the first match (from where the search command was
+// started) of the text typed so far. Any search for previously typed text is
+// cancelled.
+func (a *app) incrementalSearch() {
+	m := a.model
+	s := m.incSearch
+	if s == nil {
+		return
+	}
+	if s.cancel != nil {
+		s.cancel.Cancel()
+	}
+	s.running = false
+
+	re, err := compileSearch(m.cmd.Text, m.searchModes)
+	if err != nil || m.cmd.Text == "" {
+		if m.offset != s.origin {
+			m.moveToOffset(s.origin)
+		}
+		return
+	}
+
+	cancel := new(Cancellable)
+	s.cancel = cancel
+	s.running = true
+	v := m.lineView()
+	size := m.fileSize
+	wrap := m.config.WrapSearch
+	go func() {
+		sr := &search{
+			content: m.content,
+			re:      re,
+			v:       v,
+			lo:      s.origin,
+			hi:      size,
+			size:    size,
+			cancel:  cancel,
+		}
+		offset, found, _, err := sr.run(func(int, int) {})
+		if err == nil && !found && wrap {
+			sr.lo, sr.hi = 0, s.origin
+			offset, found, _, err = sr.run(func(int, int) {})
+		}
+		a.reactor.Enque(func() {
+			if cancel.Cancelled() || m.incSearch != s {
+				return // Superseded.
+			}
+			s.running = false
+			if err != nil {
+				log.Warn("Incremental search failed: %v", err)
+			}
+			if !found {
+				offset = s.origin
+			}
+			if offset != m.offset {
+				m.pauseFollowing()
+				m.moveToOffset(offset)
+			}
+			if s.committed {
+				m.incSearch = nil
+			}
+		}, "incremental search")
+	}()
+}
+
+// Keeps the position found by the incremental search (or the position it's
+// yet to find).
+func (m *Model) commitIncSearch() {
+	s := m.incSearch
+	if s == nil {
+		return
+	}
+	if s.origin != m.offset || s.running {
+		m.recordJump(s.origin)
+	}
+	if s.running {
+		s.committed = true
+	} else {
+		m.incSearch = nil
+	}
+}
+
+// Returns to where the incremental search started.
+func (m *Model) abandonIncSearch() {
+	if s := m.incSearch; s != nil {
+		m.cancelIncSearch()
+		if m.offset != s.origin {
+			m.moveToOffset(s.origin)
+		}
+	}
+}
+
+func (m *Model) cancelIncSearch() {
+	if m.incSearch != nil && m.incSearch.cancel != nil {
+		m.incSearch.cancel.Cancel()
+	}
+	m.incSearch = nil
+}
+
+// Modifiers that change how the search text is interpreted.
+type searchModes struct {
+	smartCase bool // Ignore case unless the text contains a capital.
+	literal   bool // Match the text exactly, rather than as a regex.
+	wholeWord bool // Only match whole words.
+}
+
+func (s searchModes) String() string {
+	var modes []string
+	if s.smartCase {
+		modes = append(modes, "smart-case")
+	}
+	if s.literal {
+		modes = append(modes, "literal")
+	}
+	if s.wholeWord {
+		modes = append(modes, "word")
+	}
+	return strings.Join(modes, ",")
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// Compiles search text into a regex, according to the search modes.
+func compileSearch(text string, modes searchModes) (*regexp.Regexp, error) {
+	pattern := text
+	if modes.literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if modes.wholeWord {
+		// Literal text that starts or ends with a non-word character can't
+		// have a word boundary there, so the boundary is only required
+		// next to word characters.
+		start, end := `\b`, `\b`
+		if modes.literal && text != "" {
+			if !isWordByte(text[0]) {
+				start = ""
+			}
+			if !isWordByte(text[len(text)-1]) {
+				end = ""
+			}
+		}
+		pattern = start + `(?:` + pattern + `)` + end
+	}
+	if modes.smartCase && strings.IndexFunc(text, unicode.IsUpper) < 0 {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
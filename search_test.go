@@ -0,0 +1,226 @@
+package main
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchResumeMatches(t *testing.T) {
+	r := &searchResume{re: "abc", reverse: false, origin: 100, loadGen: 3, offset: 5000}
+	for i, test := range []struct {
+		re      string
+		reverse bool
+		origin  int
+		loadGen int
+		want    bool
+	}{
+		{"abc", false, 100, 3, true},
+		{"abd", false, 100, 3, false},
+		{"abc", true, 100, 3, false},
+		{"abc", false, 101, 3, false},
+		{"abc", false, 100, 4, false},
+	} {
+		if got := r.matches(test.re, test.reverse, test.origin, test.loadGen); got != test.want {
+			t.Errorf("%d: got=%v want=%v", i, got, test.want)
+		}
+	}
+
+	var none *searchResume
+	if none.matches("abc", false, 100, 3) {
+		t.Errorf("nil resume should never match")
+	}
+}
+
+func TestSearchProgressDescribe(t *testing.T) {
+	p := searchProgress{offset: 250, scanned: 200, started: time.Now().Add(-time.Second)}
+	got := p.describe(1000)
+	if !strings.HasPrefix(got, "searching: offset 250 (25.0%), 0.0 MB/s") {
+		t.Errorf("unexpected description: %q", got)
+	}
+	p.resumed = true
+	if got := p.describe(0); !strings.HasPrefix(got, "resumed search: offset 250 (0.0%)") {
+		t.Errorf("unexpected description: %q", got)
+	}
+}
+
+// Finds the first (or last) complete line starting in [lo, hi) that matches,
+// the slow way.
+func naiveSearch(text string, re *regexp.Regexp, v lineView, reverse bool, lo, hi int) (int, bool) {
+	var offset int
+	var found bool
+	for pos := 0; pos < len(text); {
+		n := strings.IndexByte(text[pos:], '\n')
+		if n < 0 {
+			break
+		}
+		data := text[pos : pos+n+1]
+		if pos >= lo && pos < hi && v.allow(data) && re.MatchString(v.text(data)) {
+			offset, found = pos, true
+			if !reverse {
+				break
+			}
+		}
+		pos += n + 1
+	}
+	return offset, found
+}
+
+func TestSearchMatchesNaiveSearch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var lines []string
+	for i := 0; i < 300; i++ {
+		line := strings.Repeat("x", rnd.Intn(20))
+		switch rnd.Intn(10) {
+		case 0:
+			line += "needle"
+		case 1:
+			line += "nee\bneedle"
+		case 2:
+			line += "NEEDLE"
+		}
+		lines = append(lines, line+strings.Repeat("y", rnd.Intn(5)))
+	}
+	text := strings.Join(lines, "\n") + "\npartial needle"
+	content := NewBufferContent()
+	content.Write([]byte(text))
+
+	hide, err := parseFilter("!^x{3}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{"needle", "(?i)needle", "x+needle", "^needle", "ne+dle$", "nope"} {
+		re := regexp.MustCompile(pattern)
+		for _, v := range []lineView{{}, {filters: filters{hide}}} {
+			for _, reverse := range []bool{false, true} {
+				for _, chunkSize := range []int{1, 7, 64, 1000, 1 << 20} {
+					lo, hi := rnd.Intn(len(text)), len(text)
+					if reverse {
+						lo, hi = 0, rnd.Intn(len(text))
+					}
+					s := &search{
+						content:   content,
+						re:        re,
+						v:         v,
+						reverse:   reverse,
+						lo:        lo,
+						hi:        hi,
+						size:      len(text),
+						chunkSize: chunkSize,
+						cancel:    new(Cancellable),
+					}
+					got, gotFound, _, err := s.run(func(int, int) {})
+					if err != nil {
+						t.Fatal(err)
+					}
+					want, wantFound := naiveSearch(text, re, v, reverse, lo, hi)
+					if got != want || gotFound != wantFound {
+						t.Errorf("pattern=%q filters=%d reverse=%v chunk=%d lo=%d hi=%d: got=%d,%v want=%d,%v",
+							pattern, len(v.filters), reverse, chunkSize, lo, hi, got, gotFound, want, wantFound)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRequiredLiteral(t *testing.T) {
+	for _, test := range []struct {
+		re   string
+		want string
+	}{
+		{"abc", "abc"},
+		{"^abc$", "abc"},
+		{`\d+ ERROR: (foo|bar)`, " ERROR: "},
+		{"(abc)+", "abc"},
+		{"a|b", ""},
+		{"(?i)abc", ""},
+		{"x*", ""},
+		{"ab?", "a"},
+	} {
+		if got := string(requiredLiteral(regexp.MustCompile(test.re))); got != test.want {
+			t.Errorf("re=%q got=%q want=%q", test.re, got, test.want)
+		}
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	content := NewBufferContent()
+	content.Write([]byte(strings.Repeat("line\n", 100) + "needle\n"))
+	cancel := new(Cancellable)
+	cancel.Cancel()
+	s := &search{
+		content:   content,
+		re:        regexp.MustCompile("needle"),
+		lo:        10,
+		hi:        505,
+		size:      505,
+		chunkSize: 16,
+		cancel:    cancel,
+	}
+	_, found, reached, err := s.run(func(int, int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found || reached != 10 {
+		t.Errorf("found=%v reached=%d, want no match and nothing reached", found, reached)
+	}
+}
+
+func TestIncSearchCommitAndAbandon(t *testing.T) {
+	log = NullLogger{}
+	var m Model
+	m.moveToOffset(10)
+	m.StartCommandMode(SearchCommand)
+	m.moveToOffset(50) // As if a match was found while typing.
+	m.abandonIncSearch()
+	if m.offset != 10 || m.incSearch != nil {
+		t.Fatalf("abandon: offset=%d incSearch=%v", m.offset, m.incSearch)
+	}
+
+	m.StartCommandMode(SearchCommand)
+	m.moveToOffset(50)
+	m.commitIncSearch()
+	if m.offset != 50 || m.incSearch != nil {
+		t.Fatalf("commit: offset=%d incSearch=%v", m.offset, m.incSearch)
+	}
+	m.jumpBack()
+	if m.offset != 10 {
+		t.Fatalf("jump back after commit: offset=%d want=10", m.offset)
+	}
+}
+
+func TestCompileSearch(t *testing.T) {
+	for i, test := range []struct {
+		text      string
+		modes     searchModes
+		matches   []string
+		nonMatchs []string
+	}{
+		{"foo", searchModes{}, []string{"foo", "xfoox"}, []string{"FOO"}},
+		{"foo", searchModes{smartCase: true}, []string{"foo", "FOO", "Foo"}, []string{"fo"}},
+		{"Foo", searchModes{smartCase: true}, []string{"Foo"}, []string{"foo", "FOO"}},
+		{"a.b", searchModes{}, []string{"a.b", "axb"}, nil},
+		{"a.b", searchModes{literal: true}, []string{"a.b"}, []string{"axb"}},
+		{"id", searchModes{wholeWord: true}, []string{"id", "an id here", "id=3"}, []string{"idx", "pid"}},
+		{"a|b", searchModes{wholeWord: true}, []string{"a", "b c"}, []string{"ab"}},
+		{"x+", searchModes{literal: true, wholeWord: true, smartCase: true}, []string{"X+", "a x+"}, []string{"xx", "ax+"}},
+	} {
+		re, err := compileSearch(test.text, test.modes)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for _, s := range test.matches {
+			if !re.MatchString(s) {
+				t.Errorf("%d: re=%q should match %q", i, re, s)
+			}
+		}
+		for _, s := range test.nonMatchs {
+			if re.MatchString(s) {
+				t.Errorf("%d: re=%q shouldn't match %q", i, re, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+)
+
+// StateStore persists session state between runs. Each file has its own
+// state, and command history is shared between all files.
+type StateStore struct {
+	dir string
+}
+
+// FileState is the state of a model that's restored when its file is opened
+// again.
+type FileState struct {
+	Path       string
+	Identity   string // Device and inode, to detect a different file at the same path.
+	Offset     int
+	Regexes    []SavedRegex
+	WrapMode   bool
+	PrettyJSON bool
+	XPosition  int
+}
+
+type SavedRegex struct {
+	Regex string
+	Style string
+}
+
+// Gives the directory in which state is stored, following the XDG base
+// directory spec.
+func defaultStateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "dauntless"), nil
+}
+
+func NewStateStore(dir string) *StateStore {
+	return &StateStore{dir}
+}
+
+func (s *StateStore) filePath(path string) string {
+	sum := sha1.Sum([]byte(path))
+	return filepath.Join(s.dir, "files", fmt.Sprintf("%x.json", sum))
+}
+
+func (s *StateStore) historyPath() string {
+	return filepath.Join(s.dir, "history.json")
+}
+
+func fileIdentity(filename string) (string, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%d:%d", st.Dev, st.Ino), nil
+}
+
+// LoadFile loads the state of a file. False is returned if there's no state
+// for the file.
+func (s *StateStore) LoadFile(filename string) (FileState, bool, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return FileState{}, false, err
+	}
+	var state FileState
+	if ok, err := readJSON(s.filePath(path), &state); !ok || err != nil {
+		return FileState{}, false, err
+	}
+	if state.Path != path {
+		return FileState{}, false, nil // Hash collision.
+	}
+
+	identity, err := fileIdentity(filename)
+	if err != nil {
+		return FileState{}, false, err
+	}
+	if identity != state.Identity {
+		// A different file now lives at the path, so the position no
+		// longer means anything.
+		state.Offset = 0
+		state.XPosition = 0
+	}
+	return state, true, nil
+}
+
+func (s *StateStore) SaveFile(filename string, state FileState) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	state.Path = path
+	state.Identity, err = fileIdentity(filename)
+	if err != nil {
+		return err
+	}
+	return writeJSON(s.filePath(path), state)
+}
+
+// Names that command history is saved under. They're used rather than the
+// modes themselves so that history survives the modes being renumbered.
+var historyNames = map[CommandMode]string{
+	SearchCommand:   "search",
+	ColourCommand:   "colour",
+	SeekCommand:     "seek",
+	BisectCommand:   "bisect",
+	ExCommand:       "ex",
+	FilterCommand:   "filter",
+	MarkCommand:     "mark",
+	GotoMarkCommand: "goto-mark",
+	TimeCommand:     "time",
+}
+
+func (s *StateStore) LoadHistory() (map[CommandMode][]string, error) {
+	saved := map[string][]string{}
+	if _, err := readJSON(s.historyPath(), &saved); err != nil {
+		return map[CommandMode][]string{}, err
+	}
+	history := map[CommandMode][]string{}
+	for mode, name := range historyNames {
+		if hist, ok := saved[name]; ok {
+			history[mode] = hist
+		}
+	}
+	return history, nil
+}
+
+func (s *StateStore) SaveHistory(history map[CommandMode][]string) error {
+	const maxHistory = 100
+	trimmed := map[string][]string{}
+	for mode, hist := range history {
+		name, ok := historyNames[mode]
+		if !ok {
+			continue // E.g. quit, which has no useful history.
+		}
+		trimmed[name] = hist[:min(len(hist), maxHistory)]
+	}
+	return writeJSON(s.historyPath(), trimmed)
+}
+
+// Reads JSON from a file into v. False is returned if the file doesn't exist.
+func readJSON(path string, v interface{}) (bool, error) {
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return false, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// Writes v as JSON to a file, replacing it atomically.
+func writeJSON(path string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func saveRegexes(regexes []regex) []SavedRegex {
+	var saved []SavedRegex
+	for _, r := range regexes {
+		saved = append(saved, SavedRegex{r.re.String(), r.style.String()})
+	}
+	return saved
+}
+
+// Restores saved regexes. Any that can no longer be parsed are skipped.
+func restoreRegexes(saved []SavedRegex) []regex {
+	var regexes []regex
+	for _, s := range saved {
+		re, err := regexp.Compile(s.Regex)
+		if err != nil {
+			log.Warn("Could not restore regex: regex=%q err=%v", s.Regex, err)
+			continue
+		}
+		style, err := parseStyle(s.Style)
+		if err != nil {
+			log.Warn("Could not restore regex style: style=%q err=%v", s.Style, err)
+			continue
+		}
+		regexes = append(regexes, regex{style, re})
+	}
+	return regexes
+}
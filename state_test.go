@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStateStoreRoundTrip(t *testing.T) {
+	log = NullLogger{}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.log")
+	if err := os.WriteFile(filename, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStateStore(filepath.Join(dir, "state"))
+
+	if _, ok, err := store.LoadFile(filename); ok || err != nil {
+		t.Fatalf("expected no state: ok=%t err=%v", ok, err)
+	}
+
+	red, err := parseStyle("fg:196 bg:#010203 bold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	regexes := []regex{{red, regexp.MustCompile("ERR(OR)?")}}
+	saved := FileState{Offset: 6, Regexes: saveRegexes(regexes), WrapMode: true, XPosition: 3}
+	if err := store.SaveFile(filename, saved); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := store.LoadFile(filename)
+	if !ok || err != nil {
+		t.Fatalf("expected state: ok=%t err=%v", ok, err)
+	}
+	if got.Offset != 6 || !got.WrapMode || got.XPosition != 3 {
+		t.Errorf("unexpected state: %+v", got)
+	}
+	restored := restoreRegexes(got.Regexes)
+	if len(restored) != 1 || restored[0].style != red || restored[0].re.String() != "ERR(OR)?" {
+		t.Errorf("unexpected regexes: %v", restored)
+	}
+
+	// A new file at the same path loses the position.
+	other := filepath.Join(dir, "other")
+	if err := os.WriteFile(other, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(other, filename); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err = store.LoadFile(filename)
+	if !ok || err != nil || got.Offset != 0 || got.XPosition != 0 || !got.WrapMode {
+		t.Errorf("unexpected state after replacement: ok=%t err=%v state=%+v", ok, err, got)
+	}
+
+	history := map[CommandMode][]string{SearchCommand: {"b", "a"}, QuitCommand: {"y"}}
+	if err := store.SaveHistory(history); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(store.historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"search"`) {
+		t.Errorf("history isn't keyed by mode name: %s", buf)
+	}
+	gotHistory, err := store.LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (map[CommandMode][]string{SearchCommand: {"b", "a"}}); !reflect.DeepEqual(gotHistory, want) {
+		t.Errorf("history: got=%v want=%v", gotHistory, want)
+	}
+}
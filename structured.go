@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Keys holding the timestamp, level and message of a JSON log entry, in order
+// of preference. They're shown first, without their keys.
+var (
+	jsonTimeKeys  = []string{"ts", "time", "timestamp", "@timestamp", "t"}
+	jsonLevelKeys = []string{"level", "lvl", "severity", "@level"}
+	jsonMsgKeys   = []string{"msg", "message", "@message"}
+)
+
+var (
+	jsonTimeStyle   = MixStyle(Blue, Default)
+	jsonMsgStyle    = Style{}.with(Bold)
+	jsonKeyStyle    = MixStyle(Cyan, Default)
+	jsonStringStyle = MixStyle(Green, Default)
+	jsonOtherStyle  = MixStyle(Magenta, Default)
+	jsonNestedStyle = MixStyle(Yellow, Default)
+)
+
+// How JSON log entries are displayed.
+type jsonDisplay int
+
+const (
+	jsonRaw jsonDisplay = iota
+	jsonCompact
+	jsonExpanded
+)
+
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// Parses a JSON object, keeping its fields in order. Anything other than a
+// single object (with at least one field) isn't accepted.
+func parseJSONObject(line string) ([]jsonField, bool) {
+	if !strings.HasPrefix(strings.TrimLeft(line, " \t"), "{") {
+		return nil, false // Fast path for lines that aren't JSON.
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		fields = append(fields, jsonField{key, value})
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('}') {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return fields, len(fields) > 0
+}
+
+// A styledText is text with the style of each of its bytes.
+type styledText struct {
+	text   strings.Builder
+	styles []Style
+}
+
+func (s *styledText) add(str string, style Style) {
+	s.text.WriteString(str)
+	for i := 0; i < len(str); i++ {
+		s.styles = append(s.styles, style)
+	}
+}
+
+// Formats a JSON log entry for display, as its timestamp, level and message
+// followed by the rest of its fields as key=value pairs. If expanded, each of
+// the other fields goes on its own row (rows are separated by newlines).
+// Lines that aren't JSON objects aren't formatted.
+func formatJSONLine(line string, expanded bool) (string, []Style, bool) {
+	fields, ok := parseJSONObject(line)
+	if !ok {
+		return "", nil, false
+	}
+
+	var out styledText
+	used := make([]bool, len(fields))
+	header := func(keys []string, style func(string) Style) {
+		for _, key := range keys {
+			for i, f := range fields {
+				if used[i] || f.key != key {
+					continue
+				}
+				text, _ := jsonValueText(f.value, true)
+				if out.text.Len() > 0 {
+					out.add(" ", Style{})
+				}
+				out.add(text, style(text))
+				used[i] = true
+				return
+			}
+		}
+	}
+	header(jsonTimeKeys, func(string) Style { return jsonTimeStyle })
+	header(jsonLevelKeys, levelStyle)
+	header(jsonMsgKeys, func(string) Style { return jsonMsgStyle })
+
+	for i, f := range fields {
+		if used[i] {
+			continue
+		}
+		if out.text.Len() > 0 {
+			if expanded {
+				out.add("\n  ", Style{})
+			} else {
+				out.add(" ", Style{})
+			}
+		}
+		text, style := jsonValueText(f.value, false)
+		out.add(quoteIfNeeded(f.key), jsonKeyStyle)
+		out.add("=", Style{})
+		out.add(text, style)
+	}
+	return out.text.String(), out.styles, true
+}
+
+// Gives the text to display for a JSON value, and its style. Strings are
+// quoted if they'd be ambiguous, unless bare is set (in which case they're
+// only quoted if they contain control characters).
+func jsonValueText(raw json.RawMessage, bare bool) (string, Style) {
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return string(raw), jsonStringStyle
+		}
+		if bare && strings.IndexFunc(s, isControl) < 0 {
+			return s, jsonStringStyle
+		}
+		return quoteIfNeeded(s), jsonStringStyle
+	case '{', '[':
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return string(raw), jsonNestedStyle
+		}
+		return buf.String(), jsonNestedStyle
+	default:
+		return string(raw), jsonOtherStyle
+	}
+}
+
+// Quotes a string if it's empty, or contains spaces, quotes, equals signs or
+// control characters.
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"") || strings.IndexFunc(s, isControl) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func isControl(r rune) bool {
+	return r < 32 || r == 127
+}
+
+// Gives the style for a log level, so that errors and warnings stand out.
+func levelStyle(level string) Style {
+	level = strings.ToLower(level)
+	switch {
+	case strings.HasPrefix(level, "err"), strings.HasPrefix(level, "fatal"),
+		strings.HasPrefix(level, "crit"), strings.HasPrefix(level, "panic"),
+		strings.HasPrefix(level, "emerg"), strings.HasPrefix(level, "alert"):
+		return MixStyle(Red, Default).with(Bold)
+	case strings.HasPrefix(level, "warn"):
+		return MixStyle(Yellow, Default).with(Bold)
+	case strings.HasPrefix(level, "info"), strings.HasPrefix(level, "notice"):
+		return MixStyle(Green, Default)
+	default:
+		return MixStyle(Blue, Default)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatJSONLine(t *testing.T) {
+	const entry = `{"msg":"request failed","level":"error","ts":"2024-05-01T10:00:00Z",` +
+		`"path":"/x y","status":500,"ok":false,"user":{ "id": 7 },"note":"","tab":"a\tb"}`
+	for _, test := range []struct {
+		line     string
+		expanded bool
+		want     string
+		ok       bool
+	}{
+		{
+			line: entry,
+			want: `2024-05-01T10:00:00Z error request failed path="/x y" status=500 ok=false user={"id":7} note="" tab="a\tb"`,
+			ok:   true,
+		},
+		{
+			line:     entry,
+			expanded: true,
+			want:     "2024-05-01T10:00:00Z error request failed\n  path=\"/x y\"\n  status=500\n  ok=false\n  user={\"id\":7}\n  note=\"\"\n  tab=\"a\\tb\"",
+			ok:       true,
+		},
+		{
+			line:     `{"a":1,"b":"x"}`,
+			expanded: true,
+			want:     "a=1\n  b=x",
+			ok:       true,
+		},
+		{
+			line: `{"message":"multi\nline"}`,
+			want: `"multi\nline"`,
+			ok:   true,
+		},
+		{line: "plain text"},
+		{line: `{"a":1} trailing`},
+		{line: `{"a":1`},
+		{line: `{}`},
+		{line: `[1,2]`},
+	} {
+		got, styles, ok := formatJSONLine(test.line, test.expanded)
+		if ok != test.ok || got != test.want {
+			t.Errorf("line=%q expanded=%t: got %q (ok=%t), want %q (ok=%t)",
+				test.line, test.expanded, got, ok, test.want, test.ok)
+		}
+		if len(styles) != len(got) {
+			t.Errorf("line=%q: got %d styles for %d bytes", test.line, len(styles), len(got))
+		}
+	}
+}
+
+func TestFormatJSONLineStyles(t *testing.T) {
+	text, styles, ok := formatJSONLine(`{"level":"WARN","k":"v"}`, false)
+	if !ok || text != "WARN k=v" {
+		t.Fatalf("got %q (ok=%t)", text, ok)
+	}
+	want := []Style{
+		levelStyle("WARN"), levelStyle("WARN"), levelStyle("WARN"), levelStyle("WARN"),
+		{}, jsonKeyStyle, {}, jsonStringStyle,
+	}
+	for i := range want {
+		if styles[i] != want[i] {
+			t.Errorf("%d (%q): got %v, want %v", i, text[i], styles[i], want[i])
+		}
+	}
+}
+
+func TestWrapRowsWithBreaks(t *testing.T) {
+	text, _, _ := formatJSONLine(`{"msg":"hello","a":1,"b":2}`, true)
+	cells, _ := renderLine(text, make([]Style, len(text)), 8)
+	for _, test := range []struct {
+		width, want int
+	}{
+		{80, 3},
+		{5, 3},
+		{4, 6},
+		{3, 6},
+	} {
+		if got := wrapRows(cells, test.width, test.width); got != test.want {
+			t.Errorf("width=%d: got %d rows, want %d", test.width, got, test.want)
+		}
+	}
+}
@@ -1,77 +1,234 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Style is the appearance of a screen cell. The zero value is the terminal's
+// default appearance.
+type Style struct {
+	fg, bg Colour
+	attrs  Attr
+}
 
-type Style uint8
+// Colour is either the terminal's default colour, one of the 256 indexed
+// colours, or a 24-bit RGB colour.
+type Colour uint32
 
 const (
-	fgMask Style = 0x0f
-	bgMask Style = 0xf0
+	colourIndexed Colour = 1 << 24
+	colourRGB     Colour = 2 << 24
+	colourKind    Colour = 3 << 24
 )
 
-func MixStyle(fg, bg Style) Style {
-	return fg | (bg << 4)
+func IndexedColour(idx uint8) Colour {
+	return colourIndexed | Colour(idx)
 }
 
-func (s Style) fg() int {
-	return int(30 + ((s & fgMask) ^ xorConst))
+func RGBColour(r, g, b uint8) Colour {
+	return colourRGB | Colour(r)<<16 | Colour(g)<<8 | Colour(b)
 }
 
-func (s Style) bg() int {
-	return int(40 + (((s & bgMask) >> 4) ^ xorConst))
+const (
+	Default Colour = 0
+	Black          = colourIndexed | 0
+	Red            = colourIndexed | 1
+	Green          = colourIndexed | 2
+	Yellow         = colourIndexed | 3
+	Blue           = colourIndexed | 4
+	Magenta        = colourIndexed | 5
+	Cyan           = colourIndexed | 6
+	White          = colourIndexed | 7
+)
+
+type Attr uint8
+
+const (
+	Bold Attr = 1 << iota
+	Italic
+	Underline
+	Reverse
+)
+
+var attrCodes = []struct {
+	attr    Attr
+	name    string
+	on, off int
+}{
+	{Bold, "bold", 1, 22},
+	{Italic, "italic", 3, 23},
+	{Underline, "underline", 4, 24},
+	{Reverse, "reverse", 7, 27},
 }
 
-func (s *Style) setFG(fg Style) {
-	*s &= ^fgMask
-	*s |= fg
+// Invert is the style used for the status line and overlays.
+var Invert = Style{attrs: Reverse}
+
+func MixStyle(fg, bg Colour) Style {
+	return Style{fg: fg, bg: bg}
 }
 
-func (s *Style) setBG(bg Style) {
-	*s &= ^bgMask
-	*s |= (bg << 4)
+func (s Style) with(a Attr) Style {
+	s.attrs |= a
+	return s
 }
 
-func (s Style) inverted() bool {
-	return s&fgMask == Invert || (s&bgMask)>>4 == Invert
+// Gives the SGR parameters that select a colour, for either the foreground
+// (base 30) or background (base 40).
+func (c Colour) sgr(base int) string {
+	switch c & colourKind {
+	case colourIndexed:
+		idx := int(c & 0xff)
+		switch {
+		case idx < 8:
+			return strconv.Itoa(base + idx)
+		case idx < 16:
+			return strconv.Itoa(base + 60 + idx - 8)
+		default:
+			return fmt.Sprintf("%d;5;%d", base+8, idx)
+		}
+	case colourRGB:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, (c>>16)&0xff, (c>>8)&0xff, c&0xff)
+	default:
+		return strconv.Itoa(base + 9)
+	}
 }
 
+// Gives the escape code that sets the style from scratch.
 func (s Style) escapeCode() string {
-	if s.inverted() {
-		return "\x1b[0;7m"
-	} else {
-		return fmt.Sprintf("\x1b[0;%d;%dm", s.fg(), s.bg())
+	params := []string{"0"}
+	for _, ac := range attrCodes {
+		if s.attrs&ac.attr != 0 {
+			params = append(params, strconv.Itoa(ac.on))
+		}
+	}
+	if s.fg != Default {
+		params = append(params, s.fg.sgr(30))
 	}
+	if s.bg != Default {
+		params = append(params, s.bg.sgr(40))
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
 }
 
-const (
-	xorConst Style = 9
-
-	Black   Style = 0 ^ xorConst
-	Red     Style = 1 ^ xorConst
-	Green   Style = 2 ^ xorConst
-	Yellow  Style = 3 ^ xorConst
-	Blue    Style = 4 ^ xorConst
-	Magenta Style = 5 ^ xorConst
-	Cyan    Style = 6 ^ xorConst
-	White   Style = 7 ^ xorConst
-	Invert  Style = 8 ^ xorConst
-	Default Style = 9 ^ xorConst
-)
+// Gives the escape code that changes the terminal from one style to another,
+// only including the parameters that differ.
+func (s Style) transitionFrom(from Style) string {
+	if s == from {
+		return ""
+	}
+	var params []string
+	for _, ac := range attrCodes {
+		if from.attrs&ac.attr != 0 && s.attrs&ac.attr == 0 {
+			params = append(params, strconv.Itoa(ac.off))
+		}
+	}
+	for _, ac := range attrCodes {
+		if s.attrs&ac.attr != 0 && from.attrs&ac.attr == 0 {
+			params = append(params, strconv.Itoa(ac.on))
+		}
+	}
+	if s.fg != from.fg {
+		params = append(params, s.fg.sgr(30))
+	}
+	if s.bg != from.bg {
+		params = append(params, s.bg.sgr(40))
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+var colourNames = []struct {
+	name   string
+	colour Colour
+}{
+	{"default", Default},
+	{"black", Black},
+	{"red", Red},
+	{"green", Green},
+	{"yellow", Yellow},
+	{"blue", Blue},
+	{"magenta", Magenta},
+	{"cyan", Cyan},
+	{"white", White},
+}
+
+func (c Colour) String() string {
+	for _, cn := range colourNames {
+		if cn.colour == c {
+			return cn.name
+		}
+	}
+	if c&colourKind == colourRGB {
+		return fmt.Sprintf("#%06x", uint32(c&0xffffff))
+	}
+	return strconv.Itoa(int(c & 0xff))
+}
 
 func (s Style) String() string {
-	if str, ok := map[Style]string{
-		Black:   "Black",
-		Red:     "Red",
-		Green:   "Green",
-		Yellow:  "Yellow",
-		Blue:    "Blue",
-		Magenta: "Magenta",
-		Cyan:    "Cyan",
-		White:   "White",
-		Invert:  "Invert",
-		Default: "Default",
-	}[s]; ok {
-		return str
-	}
-	return "???"
+	parts := []string{"fg:" + s.fg.String(), "bg:" + s.bg.String()}
+	for _, ac := range attrCodes {
+		if s.attrs&ac.attr != 0 {
+			parts = append(parts, ac.name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parses a colour given by name, 256 colour index, or as #rrggbb.
+func parseColour(str string) (Colour, error) {
+	for _, cn := range colourNames {
+		if cn.name == str {
+			return cn.colour, nil
+		}
+	}
+	if strings.HasPrefix(str, "#") && len(str) == 7 {
+		rgb, err := strconv.ParseUint(str[1:], 16, 32)
+		if err == nil {
+			return RGBColour(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)), nil
+		}
+	}
+	if idx, err := strconv.ParseUint(str, 10, 8); err == nil {
+		return IndexedColour(uint8(idx)), nil
+	}
+	return 0, fmt.Errorf("invalid colour (use a name, 0-255, or #rrggbb): %v", str)
+}
+
+// Parses a style specification. It's either two digits [0-8][0-8] giving the
+// foreground and background from the basic colours, or a space separated
+// list of fg:<colour>, bg:<colour> and attribute names.
+func parseStyle(spec string) (Style, error) {
+	if len(spec) == 2 && spec[0] >= '0' && spec[0] <= '8' && spec[1] >= '0' && spec[1] <= '8' {
+		return MixStyle(colours[spec[0]-'0'], colours[spec[1]-'0']), nil
+	}
+	var s Style
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return Style{}, fmt.Errorf("empty style")
+	}
+	for _, field := range fields {
+		var err error
+		switch {
+		case strings.HasPrefix(field, "fg:"):
+			s.fg, err = parseColour(field[3:])
+		case strings.HasPrefix(field, "bg:"):
+			s.bg, err = parseColour(field[3:])
+		default:
+			found := false
+			for _, ac := range attrCodes {
+				if field == ac.name {
+					s.attrs |= ac.attr
+					found = true
+				}
+			}
+			if !found {
+				err = fmt.Errorf("unknown style attribute: %v", field)
+			}
+		}
+		if err != nil {
+			return Style{}, err
+		}
+	}
+	return s, nil
 }
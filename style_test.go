@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestStyleEscapeCodes(t *testing.T) {
+	red := MixStyle(Red, Default)
+	for i, test := range []struct {
+		from, to   Style
+		transition string
+		escape     string
+	}{
+		{Style{}, Style{}, "", "\x1b[0m"},
+		{Style{}, red, "\x1b[31m", "\x1b[0;31m"},
+		{red, red.with(Bold), "\x1b[1m", "\x1b[0;1;31m"},
+		{red.with(Bold | Underline), red.with(Underline), "\x1b[22m", "\x1b[0;4;31m"},
+		{red, MixStyle(IndexedColour(200), Blue), "\x1b[38;5;200;44m", "\x1b[0;38;5;200;44m"},
+		{Invert, MixStyle(IndexedColour(9), RGBColour(1, 2, 255)), "\x1b[27;91;48;2;1;2;255m", "\x1b[0;91;48;2;1;2;255m"},
+	} {
+		if got := test.to.transitionFrom(test.from); got != test.transition {
+			t.Errorf("%d: transition got=%q want=%q", i, got, test.transition)
+		}
+		if got := test.to.escapeCode(); got != test.escape {
+			t.Errorf("%d: escape got=%q want=%q", i, got, test.escape)
+		}
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	for i, test := range []struct {
+		spec string
+		want Style
+		ok   bool
+	}{
+		{"21", MixStyle(Red, Black), true},
+		{"00", Style{}, true},
+		{"fg:red bold", MixStyle(Red, Default).with(Bold), true},
+		{"fg:196 bg:#0a0b0c underline italic", MixStyle(IndexedColour(196), RGBColour(10, 11, 12)).with(Underline | Italic), true},
+		{"99", Style{}, false},
+		{"fg:256", Style{}, false},
+		{"blink", Style{}, false},
+		{"", Style{}, false},
+	} {
+		got, err := parseStyle(test.spec)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("%d: spec=%q got=%v err=%v want=%v", i, test.spec, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A substitution rewrites the matches of its regex in each line at display
+// time. The file itself is never changed.
+type substitution struct {
+	re     *regexp.Regexp
+	repl   string
+	global bool // Replace every match, rather than just the first.
+}
+
+func (s substitution) String() string {
+	var flags string
+	if s.global {
+		flags = "g"
+	}
+	esc := func(str string) string { return strings.Replace(str, "/", `\/`, -1) }
+	return "s/" + esc(s.re.String()) + "/" + esc(s.repl) + "/" + flags
+}
+
+func (s substitution) apply(line string) string {
+	if s.global {
+		return s.re.ReplaceAllString(line, s.repl)
+	}
+	match := s.re.FindStringSubmatchIndex(line)
+	if match == nil {
+		return line
+	}
+	var out []byte
+	out = append(out, line[:match[0]]...)
+	out = s.re.ExpandString(out, s.repl, line, match)
+	out = append(out, line[match[1]:]...)
+	return string(out)
+}
+
+// Substitutions is a stack of substitutions, applied in the order that they
+// were added. It's safe to use concurrently.
+type substitutions []substitution
+
+func (ss substitutions) apply(line string) string {
+	for _, s := range ss {
+		line = s.apply(line)
+	}
+	return line
+}
+
+// Parses a substitute command of the form s/regex/replacement/flags. As in
+// vim, any punctuation character can be used as the delimiter, and the
+// delimiter can be escaped with a backslash. The replacement can refer to
+// capture groups using $1 or ${name}. The only flag is 'g' (replace all
+// matches).
+func parseSubstitution(cmd string) (substitution, error) {
+	if len(cmd) < 2 || cmd[0] != 's' || !isSubstituteDelim(cmd[1]) {
+		return substitution{}, fmt.Errorf("invalid substitute command (should be s/regex/replacement/): %v", cmd)
+	}
+	parts := splitUnescaped(cmd[2:], cmd[1])
+	if len(parts) < 2 || len(parts) > 3 {
+		return substitution{}, fmt.Errorf("invalid substitute command (should be s/regex/replacement/): %v", cmd)
+	}
+	var s substitution
+	if len(parts) == 3 {
+		for _, flag := range parts[2] {
+			if flag != 'g' {
+				return substitution{}, fmt.Errorf("invalid substitute flag: %c", flag)
+			}
+			s.global = true
+		}
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return substitution{}, err
+	}
+	s.re = re
+	s.repl = parts[1]
+	return s, nil
+}
+
+func isSubstituteDelim(b byte) bool {
+	return b > ' ' && b <= '~' && b != '\\' &&
+		!(b >= 'a' && b <= 'z') && !(b >= 'A' && b <= 'Z') && !(b >= '0' && b <= '9')
+}
+
+// Splits a string on a delimiter, except where the delimiter is escaped with
+// a backslash (the backslash is then removed). Other escapes are kept as is.
+func splitUnescaped(str string, delim byte) []string {
+	var parts []string
+	var part []byte
+	for i := 0; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && i+1 < len(str) && str[i+1] == delim:
+			part = append(part, delim)
+			i++
+		case str[i] == '\\' && i+1 < len(str):
+			part = append(part, str[i], str[i+1])
+			i++
+		case str[i] == delim:
+			parts = append(parts, string(part))
+			part = nil
+		default:
+			part = append(part, str[i])
+		}
+	}
+	return append(parts, string(part))
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestSubstitution(t *testing.T) {
+	for i, test := range []struct {
+		cmds []string
+		in   string
+		want string
+	}{
+		{[]string{"s/a/b/"}, "banana", "bbnana"},
+		{[]string{"s/a/b/g"}, "banana", "bbnbnb"},
+		{[]string{"s/a/b"}, "banana", "bbnana"},
+		{[]string{`s/^\d+ //`}, "123 message", "message"},
+		{[]string{`s/(\w+)@(\w+)/$2 at $1/`}, "user@host", "host at user"},
+		{[]string{`s/\//|/g`}, "a/b/c", "a|b|c"},
+		{[]string{`s#/#|#g`}, "a/b/c", "a|b|c"},
+		{[]string{`s/[0-9a-f]{8}-[0-9a-f-]{27}/<uuid>/g`}, "id=123e4567-e89b-12d3-a456-426614174000.", "id=<uuid>."},
+		{[]string{"s/a/b/g", "s/b/c/"}, "ab", "cb"},
+	} {
+		var subs substitutions
+		for _, cmd := range test.cmds {
+			s, err := parseSubstitution(cmd)
+			if err != nil {
+				t.Fatalf("%d: could not parse %q: %v", i, cmd, err)
+			}
+			subs = append(subs, s)
+		}
+		if got := subs.apply(test.in); got != test.want {
+			t.Errorf("%d: got=%q want=%q", i, got, test.want)
+		}
+	}
+}
+
+func TestParseSubstitutionErrors(t *testing.T) {
+	for _, cmd := range []string{
+		"s",
+		"s/a",
+		"sa/b/",
+		"s/a/b/c/d",
+		"s/a/b/x",
+		"s/(/b/",
+	} {
+		if _, err := parseSubstitution(cmd); err == nil {
+			t.Errorf("expected error: %q", cmd)
+		}
+	}
+}
+
+func TestSubstitutionString(t *testing.T) {
+	for _, cmd := range []string{"s/a/b/", `s/a\/b/c/g`, `s#x/y#z#`} {
+		s, err := parseSubstitution(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reparsed, err := parseSubstitution(s.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reparsed.String() != s.String() || reparsed.apply("a/bx/y") != s.apply("a/bx/y") {
+			t.Errorf("did not round trip: %q -> %q", cmd, s.String())
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Number of samples taken per histogram bucket when sampling the timeline.
+const timelineSamplesPerBucket = 16
+
+// A timelineSample is the line containing an evenly spaced byte offset.
+type timelineSample struct {
+	offset  int     // Start of the sampled line.
+	weight  float64 // Estimated number of visible lines that the sample represents.
+	hit     bool    // Whether the line matches the regex.
+	t       time.Time
+	hasTime bool
+}
+
+// A timeline is a sample of the content, shown as a histogram of the log
+// volume (and regex matches) against either time or position in the file.
+type timeline struct {
+	samples  []timelineSample
+	re       *regexp.Regexp
+	size     int
+	hasTimes bool
+	byTime   bool
+	selected int // Selected bucket.
+}
+
+type timelineBucket struct {
+	lines, hits float64
+	offset      int // Start of the first sampled line, or -1 if empty.
+	from, to    time.Time
+}
+
+// Samples n lines at evenly spaced offsets through the content. Each line is
+// picked with probability proportional to its length, so weighting each
+// sample by the inverse of its length gives an unbiased estimate of the number
+// of lines around it. A trailing partial line (e.g. one that's still being
+// written) isn't sampled.
+func sampleTimeline(
+	content Content, size, n int, v lineView, re *regexp.Regexp,
+	layouts []timeLayout, cancel *Cancellable, progress func(done int),
+) ([]timelineSample, error) {
+	size, err := completeLinesEnd(content, size)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	now := time.Now()
+	samples := make([]timelineSample, 0, n)
+	for i := 0; i < n; i++ {
+		if cancel.Cancelled() {
+			return nil, nil
+		}
+		if progress != nil && i%256 == 0 {
+			progress(i)
+		}
+		line, start, err := lineAt(content, int((2*int64(i)+1)*int64(size)/(2*int64(n))))
+		if err != nil {
+			return nil, err
+		}
+		s := timelineSample{offset: start}
+		text := v.text(line)
+		if v.allow(line) {
+			s.weight = float64(size) / float64(n) / float64(len(line))
+			s.hit = re != nil && re.MatchString(text)
+		}
+		s.t, s.hasTime = lineTime(text, layouts, now)
+		samples = append(samples, s)
+	}
+
+	// Lines without a timestamp (e.g. stack traces) take the time of the
+	// closest earlier timestamped line.
+	var last time.Time
+	var have bool
+	for i := range samples {
+		if samples[i].hasTime {
+			last, have = samples[i].t, true
+		} else if have {
+			samples[i].t, samples[i].hasTime = last, true
+		}
+	}
+	return samples, nil
+}
+
+// Gives the range of the sampled timestamps.
+func (t *timeline) timeRange() (time.Time, time.Time) {
+	var lo, hi time.Time
+	for _, s := range t.samples {
+		if !s.hasTime {
+			continue
+		}
+		if lo.IsZero() || s.t.Before(lo) {
+			lo = s.t
+		}
+		if hi.IsZero() || s.t.After(hi) {
+			hi = s.t
+		}
+	}
+	return lo, hi
+}
+
+// Gives the bucket (out of n) that a sample falls into.
+func (t *timeline) bucketIdx(s timelineSample, n int, lo, hi time.Time) int {
+	if !t.byTime {
+		return int(int64(s.offset) * int64(n) / int64(max(1, t.size)))
+	}
+	if !s.hasTime {
+		return 0
+	}
+	span := hi.Sub(lo) + 1
+	return int(float64(s.t.Sub(lo)) / float64(span) * float64(n))
+}
+
+// Sorts the samples into n buckets.
+func (t *timeline) buckets(n int) []timelineBucket {
+	buckets := make([]timelineBucket, n)
+	lo, hi := t.timeRange()
+	for i := range buckets {
+		buckets[i].offset = -1
+		if t.byTime {
+			span := hi.Sub(lo) + 1
+			buckets[i].from = lo.Add(time.Duration(float64(span) * float64(i) / float64(n)))
+			buckets[i].to = lo.Add(time.Duration(float64(span) * float64(i+1) / float64(n)))
+		}
+	}
+	for _, s := range t.samples {
+		b := &buckets[clamp(t.bucketIdx(s, n, lo, hi), 0, n-1)]
+		b.lines += s.weight
+		if s.hit {
+			b.hits += s.weight
+		}
+		if b.offset == -1 || s.offset < b.offset {
+			b.offset = s.offset
+		}
+	}
+	return buckets
+}
+
+// Gives the bucket (out of n) containing an offset.
+func (t *timeline) bucketOf(offset, n int) int {
+	lo, hi := t.timeRange()
+	idx := 0
+	for i, s := range t.samples {
+		if s.offset > offset {
+			break
+		}
+		idx = i
+	}
+	if len(t.samples) == 0 {
+		return 0
+	}
+	return clamp(t.bucketIdx(t.samples[idx], n, lo, hi), 0, n-1)
+}
+
+// Gives the offset to jump to for a bucket. Empty buckets (possible when
+// plotting against time) jump to the next non-empty bucket.
+func (t *timeline) jumpOffset(bucket, n int) (int, bool) {
+	buckets := t.buckets(n)
+	for i := bucket; i < n; i++ {
+		if buckets[i].offset >= 0 {
+			return buckets[i].offset, true
+		}
+	}
+	return 0, false
+}
+
+// Describes the range covered by a bucket, out of n.
+func (t *timeline) describeBucket(b timelineBucket, idx, n int) string {
+	var desc string
+	if t.byTime {
+		desc = fmt.Sprintf("%s to %s", b.from.Format(timelineTimeFormat), b.to.Format(timelineTimeFormat))
+	} else {
+		desc = fmt.Sprintf("%.1f%% to %.1f%%", 100*float64(idx)/float64(n), 100*float64(idx+1)/float64(n))
+	}
+	desc += fmt.Sprintf(": ~%.0f lines", b.lines)
+	if t.re != nil {
+		desc += fmt.Sprintf(", ~%.0f matches", b.hits)
+	}
+	return desc
+}
+
+const timelineTimeFormat = "2006-01-02 15:04:05"
+
+// Gives the number of timeline buckets that fit across the screen.
+func timelineBuckets(cols int) int {
+	return max(1, cols-4)
+}
+
+// Samples the content (in the background) and then shows the timeline.
+func (a *app) showTimeline() {
+	m := a.model
+	if m.fileSize == 0 {
+		m.setMessage("nothing to plot, the file is empty")
+		return
+	}
+
+	cancel := new(Cancellable)
+	m.longFileOpInProgress = true
+	m.cancelLongFileOp = cancel
+	m.longFileOpDesc = "sampling timeline (interrupt to cancel)"
+	m.msg = ""
+
+	n := timelineBuckets(m.cols) * timelineSamplesPerBucket
+	size := m.fileSize
+	v := m.lineView()
+	re := m.currentRE()
+	layouts := m.config.TimeLayouts
+	go func() {
+		progress := func(done int) {
+			a.reactor.Enque(func() {
+				if m.cancelLongFileOp == cancel && m.longFileOpInProgress {
+					m.longFileOpDesc = fmt.Sprintf("sampling timeline: %d%% (interrupt to cancel)", done*100/n)
+				}
+			}, "timeline progress")
+		}
+		samples, err := sampleTimeline(m.content, size, n, v, re, layouts, cancel, progress)
+		a.reactor.Enque(func() {
+			if m.cancelLongFileOp == cancel {
+				m.longFileOpInProgress = false
+			}
+			if cancel.Cancelled() {
+				return
+			}
+			if err != nil {
+				log.Warn("Could not sample timeline: %v", err)
+				m.setMessage(fmt.Sprintf("could not sample timeline: %v", err))
+				return
+			}
+			if len(samples) == 0 {
+				m.setMessage("nothing to plot, there are no complete lines")
+				return
+			}
+			t := &timeline{samples: samples, re: re, size: size}
+			for _, s := range samples {
+				t.hasTimes = t.hasTimes || s.hasTime
+			}
+			t.byTime = t.hasTimes
+			t.selected = t.bucketOf(m.offset, timelineBuckets(m.cols))
+			m.timeline = t
+			log.Info("Sampled timeline: samples=%d byTime=%t", len(samples), t.byTime)
+		}, "timeline sampled")
+	}()
+}
+
+// Handles key presses while the timeline is shown.
+func (a *app) timelineKeyPress(k Key) {
+	m := a.model
+	t := m.timeline
+	n := timelineBuckets(m.cols)
+	t.selected = clamp(t.selected, 0, n-1)
+	switch k {
+	case "h", LeftArrowKey:
+		t.selected = max(0, t.selected-1)
+	case "l", RightArrowKey:
+		t.selected = min(n-1, t.selected+1)
+	case "H", HomeKey:
+		t.selected = 0
+	case "L", EndKey:
+		t.selected = n - 1
+	case "a":
+		if !t.hasTimes {
+			m.setMessage("no timestamps found (see --time-layouts)")
+			return
+		}
+		offset, _ := t.jumpOffset(t.selected, n)
+		t.byTime = !t.byTime
+		t.selected = t.bucketOf(offset, n)
+	case "\n":
+		m.timeline = nil
+		if offset, ok := t.jumpOffset(t.selected, n); ok {
+			m.pauseFollowing()
+			m.jumpToOffset(offset)
+		}
+	case "T", "q":
+		m.timeline = nil
+	}
+}
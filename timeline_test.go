@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleTimeline(t *testing.T) {
+	// An hour of logs, with a burst of errors in the second half.
+	var lines []string
+	for i := 0; i < 3600; i++ {
+		level := "INFO"
+		if i >= 1800 && i%2 == 0 {
+			level = "EROR"
+		}
+		ts := time.Date(2024, 5, 1, 10, 0, i, 0, time.UTC).Format(time.RFC3339)
+		lines = append(lines, fmt.Sprintf("%s %s line\n", ts, level))
+	}
+	content := NewBufferContent()
+	content.Write([]byte(strings.Join(lines, "")))
+	size, _ := content.Size()
+
+	layouts, err := parseTimeLayouts(defaultTimeLayouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile("EROR")
+	samples, err := sampleTimeline(content, int(size), 400, lineView{}, re, layouts, new(Cancellable), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 400 {
+		t.Fatalf("got %d samples, want 400", len(samples))
+	}
+
+	for _, byTime := range []bool{false, true} {
+		tl := &timeline{samples: samples, re: re, size: int(size), hasTimes: true, byTime: byTime}
+		buckets := tl.buckets(2)
+		for i, want := range []struct{ lines, hits float64 }{{1800, 0}, {1800, 900}} {
+			got := buckets[i]
+			if math.Abs(got.lines-want.lines) > 10 || math.Abs(got.hits-want.hits) > 50 {
+				t.Errorf("byTime=%t bucket=%d: got lines=%.0f hits=%.0f, want lines=%.0f hits=%.0f",
+					byTime, i, got.lines, got.hits, want.lines, want.hits)
+			}
+		}
+		if got := tl.bucketOf(len(lines[0])*2000, 2); got != 1 {
+			t.Errorf("byTime=%t: got bucket %d for line 2000, want 1", byTime, got)
+		}
+		if offset, ok := tl.jumpOffset(1, 2); !ok || offset%len(lines[0]) != 0 {
+			t.Errorf("byTime=%t: got jump offset %d (ok=%t), want a line start", byTime, offset, ok)
+		}
+	}
+}
+
+func TestTimelineJumpSkipsEmptyBuckets(t *testing.T) {
+	at := func(min int) time.Time {
+		return time.Date(2024, 5, 1, 10, min, 0, 0, time.UTC)
+	}
+	tl := &timeline{
+		samples: []timelineSample{
+			{offset: 0, weight: 1, t: at(0), hasTime: true},
+			{offset: 10, weight: 1, t: at(1), hasTime: true},
+			{offset: 20, weight: 1, t: at(9), hasTime: true},
+		},
+		size:     30,
+		hasTimes: true,
+		byTime:   true,
+	}
+	buckets := tl.buckets(10)
+	if buckets[5].offset != -1 || buckets[5].lines != 0 {
+		t.Errorf("got bucket %+v, want empty", buckets[5])
+	}
+	for _, test := range []struct {
+		bucket int
+		offset int
+		ok     bool
+	}{
+		{0, 0, true},
+		{1, 10, true},
+		{2, 20, true},
+		{9, 20, true},
+	} {
+		offset, ok := tl.jumpOffset(test.bucket, 10)
+		if offset != test.offset || ok != test.ok {
+			t.Errorf("bucket=%d: got %d (ok=%t), want %d (ok=%t)", test.bucket, offset, ok, test.offset, test.ok)
+		}
+	}
+}
+
+func TestSampleTimelinePartialLastLine(t *testing.T) {
+	for _, test := range []struct {
+		data    string
+		samples int
+	}{
+		{"2024-05-01T10:00:00Z a\n2024-05-01T10:00:01Z b\n2024-05-01T10:00:02Z still being writ", 8},
+		{"2024-05-01T10:00:00Z only a partial line", 0},
+	} {
+		content := NewBufferContent()
+		content.Write([]byte(test.data))
+		samples, err := sampleTimeline(content, len(test.data), 8, lineView{}, nil, nil, new(Cancellable), nil)
+		if err != nil {
+			t.Fatalf("data=%q: %v", test.data, err)
+		}
+		if len(samples) != test.samples {
+			t.Errorf("data=%q: got %d samples, want %d", test.data, len(samples), test.samples)
+		}
+		for _, s := range samples {
+			if s.offset >= len("2024-05-01T10:00:00Z a\n")*2 {
+				t.Errorf("data=%q: sampled the partial line at %d", test.data, s.offset)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A timeLayout describes how timestamps are written in log lines. It's either
+// a Go time layout, or a Unix epoch timestamp in some unit.
+type timeLayout struct {
+	layout string
+	epoch  time.Duration // Unit of epoch timestamps (0 if not an epoch layout).
+}
+
+// Named layouts, which can be used in place of Go layouts.
+var namedTimeLayouts = map[string]timeLayout{
+	"rfc3339":  {layout: "2006-01-02T15:04:05Z07:00"},
+	"datetime": {layout: "2006-01-02 15:04:05"},
+	"syslog":   {layout: time.Stamp},
+	"clf":      {layout: "02/Jan/2006:15:04:05 -0700"},
+	"epoch":    {epoch: time.Second},
+	"epoch-ms": {epoch: time.Millisecond},
+}
+
+const defaultTimeLayouts = "rfc3339|datetime|syslog|clf"
+
+// Parses a '|' separated list of time layouts. Each is either the name of a
+// layout (e.g. "syslog" or "epoch-ms"), or a Go time layout.
+func parseTimeLayouts(spec string) ([]timeLayout, error) {
+	var layouts []timeLayout
+	for _, name := range strings.Split(spec, "|") {
+		if name == "" {
+			continue
+		}
+		if l, ok := namedTimeLayouts[name]; ok {
+			layouts = append(layouts, l)
+			continue
+		}
+		ref := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+		if _, err := time.Parse(name, ref.Format(name)); err != nil || ref.Format(name) == name {
+			return nil, fmt.Errorf("invalid time layout (should be a name or Go layout): %q", name)
+		}
+		layouts = append(layouts, timeLayout{layout: name})
+	}
+	return layouts, nil
+}
+
+// How far into a line timestamps are looked for.
+const timestampWindow = 128
+
+// Finds the first timestamp in a line. Timestamps without a year (e.g.
+// syslog) are assumed to be within the year up to now.
+func lineTime(line string, layouts []timeLayout, now time.Time) (time.Time, bool) {
+	t, _, ok := findTime(line, layouts, now)
+	return t, ok
+}
+
+// Finds the first timestamp in a line, along with where it was found.
+func findTime(line string, layouts []timeLayout, now time.Time) (time.Time, timeHint, bool) {
+	window := line[:min(len(line), timestampWindow)]
+	for i := 0; i < len(window); i++ {
+		if i > 0 && isWordByte(window[i-1]) || !isWordByte(window[i]) {
+			continue // Timestamps start at the start of a word.
+		}
+		for j, l := range layouts {
+			if t, ok := l.parseAt(window[i:], now); ok {
+				return t, timeHint{pos: i, layout: j, ok: true}, true
+			}
+		}
+	}
+	return time.Time{}, timeHint{}, false
+}
+
+// A timeHint records where the timestamp in a line was found. Lines from the
+// same log usually have their timestamps in the same place, so looking there
+// first avoids scanning the line.
+type timeHint struct {
+	pos    int // Offset of the timestamp in the line.
+	layout int // Index of the layout that matched.
+	ok     bool
+}
+
+// Like lineTime, but tries the place in the hint first. The hint is updated
+// to wherever the timestamp was found.
+func (h *timeHint) lineTime(line string, layouts []timeLayout, now time.Time) (time.Time, bool) {
+	if h.ok && h.pos < min(len(line), timestampWindow) && h.layout < len(layouts) &&
+		(h.pos == 0 || !isWordByte(line[h.pos-1])) && isWordByte(line[h.pos]) {
+		window := line[:min(len(line), timestampWindow)]
+		if t, ok := layouts[h.layout].parseAt(window[h.pos:], now); ok {
+			return t, true
+		}
+	}
+	t, hint, ok := findTime(line, layouts, now)
+	if ok {
+		*h = hint
+	}
+	return t, ok
+}
+
+// Parses a timestamp at the start of some text.
+func (l timeLayout) parseAt(text string, now time.Time) (time.Time, bool) {
+	if l.epoch != 0 {
+		digits := 10 // Seconds since epoch are 10 digits for many years yet.
+		if l.epoch == time.Millisecond {
+			digits = 13
+		}
+		if len(text) < digits || (len(text) > digits && isDigit(text[digits])) {
+			return time.Time{}, false
+		}
+		n, err := strconv.ParseInt(text[:digits], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, 0).Add(time.Duration(n) * l.epoch), true
+	}
+
+	if isDigit(l.layout[0]) != isDigit(text[0]) {
+		return time.Time{}, false
+	}
+
+	// The length of a timestamp can differ from the length of its layout
+	// (e.g. zones and fractional seconds), so each possible length is tried.
+	for n := min(len(text), len(l.layout)+10); n >= max(1, len(l.layout)-5); n-- {
+		t, err := time.ParseInLocation(l.layout, text[:n], time.Local)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// Layouts accepted for the target of a goto time command, in addition to the
+// layouts used for log lines. Times of day take their date from the
+// reference time.
+var (
+	targetDateLayouts = []string{
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	targetTimeOfDayLayouts = []string{
+		"15:04:05",
+		"15:04",
+	}
+)
+
+// Parses the target of a goto time command. It's either an absolute time
+// (such as "2024-05-01 14:32" or "14:32"), or relative to the reference time
+// (such as "-15m" or "+1h30m").
+func parseTargetTime(target string, layouts []timeLayout, ref time.Time) (time.Time, error) {
+	target = strings.TrimSpace(target)
+	if strings.HasPrefix(target, "-") || strings.HasPrefix(target, "+") {
+		d, err := time.ParseDuration(target)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time (should be like -15m or +1h30m): %v", target)
+		}
+		return ref.Add(d), nil
+	}
+	for _, layout := range targetDateLayouts {
+		if t, err := time.ParseInLocation(layout, target, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range targetTimeOfDayLayouts {
+		if t, err := time.ParseInLocation(layout, target, time.Local); err == nil {
+			y, m, d := ref.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, ref.Location()), nil
+		}
+	}
+	if t, ok := lineTime(target, layouts, ref); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time (should be like 14:32, 2024-05-01 14:32 or -15m): %v", target)
+}
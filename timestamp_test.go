@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineTime(t *testing.T) {
+	layouts, err := parseTimeLayouts(defaultTimeLayouts + "|epoch-ms|2006/01/02 15.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	local := func(y int, mo time.Month, d, h, mi, s int) time.Time {
+		return time.Date(y, mo, d, h, mi, s, 0, time.Local)
+	}
+	for i, test := range []struct {
+		line string
+		want time.Time
+		ok   bool
+	}{
+		{"2024-05-01T14:32:05Z INFO started", time.Date(2024, 5, 1, 14, 32, 5, 0, time.UTC), true},
+		{"2024-05-01T14:32:05.250+02:00 x", time.Date(2024, 5, 1, 12, 32, 5, 250e6, time.UTC), true},
+		{"[2024-05-01 14:32:05] x", local(2024, 5, 1, 14, 32, 5), true},
+		{"INFO 2024-05-01 14:32:05,123 x", local(2024, 5, 1, 14, 32, 5).Add(123 * time.Millisecond), true},
+		{"Feb 28 23:59:59 host sshd[1]: x", local(2024, 2, 28, 23, 59, 59), true},
+		{"Dec 31 23:59:59 host x", local(2023, 12, 31, 23, 59, 59), true}, // Last year.
+		{`1.2.3.4 - - [01/May/2024:14:32:05 +0000] "GET /"`, time.Date(2024, 5, 1, 14, 32, 5, 0, time.UTC), true},
+		{"ts=1714573925000 msg=x", time.Unix(1714573925, 0), true},
+		{"ts=17145739250001 msg=x", time.Time{}, false},
+		{"2024/05/01 14.32 custom", local(2024, 5, 1, 14, 32, 0), true},
+		{"no timestamp here", time.Time{}, false},
+		{"", time.Time{}, false},
+	} {
+		got, ok := lineTime(test.line, layouts, now)
+		if ok != test.ok || !got.Equal(test.want) {
+			t.Errorf("%d: line=%q got=%v,%v want=%v,%v", i, test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestParseTimeLayouts(t *testing.T) {
+	if _, err := parseTimeLayouts("syslog|2006-01-02"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := parseTimeLayouts("syslog|nonsense"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestParseTargetTime(t *testing.T) {
+	ref := time.Date(2024, 5, 1, 14, 0, 0, 0, time.Local)
+	for i, test := range []struct {
+		target string
+		want   time.Time
+	}{
+		{"14:32", time.Date(2024, 5, 1, 14, 32, 0, 0, time.Local)},
+		{"09:05:30", time.Date(2024, 5, 1, 9, 5, 30, 0, time.Local)},
+		{"-15m", ref.Add(-15 * time.Minute)},
+		{"+1h30m", ref.Add(90 * time.Minute)},
+		{"2023-12-25", time.Date(2023, 12, 25, 0, 0, 0, 0, time.Local)},
+		{"2023-12-25 08:00", time.Date(2023, 12, 25, 8, 0, 0, 0, time.Local)},
+		{"2023-12-25T08:00:00Z", time.Date(2023, 12, 25, 8, 0, 0, 0, time.UTC)},
+	} {
+		got, err := parseTargetTime(test.target, nil, ref)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("%d: target=%q got=%v,%v want=%v", i, test.target, got, err, test.want)
+		}
+	}
+	for _, target := range []string{"", "soon", "-15x", "25:00"} {
+		if _, err := parseTargetTime(target, nil, ref); err == nil {
+			t.Errorf("expected error: %q", target)
+		}
+	}
+}
+
+func TestTimeHint(t *testing.T) {
+	layouts, err := parseTimeLayouts(defaultTimeLayouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	var hint timeHint
+	for i, line := range []string{
+		"[2024-05-01 14:32:05] x",
+		"[2024-05-01 14:32:06] y",
+		"  at frame",
+		"no bracket 2024-05-01 14:32:07 z",
+		"2024-05-01T14:32:08Z moved",
+		"",
+	} {
+		wantTime, wantOK := lineTime(line, layouts, now)
+		got, ok := hint.lineTime(line, layouts, now)
+		if ok != wantOK || !got.Equal(wantTime) {
+			t.Errorf("%d: line=%q got=%v,%v want=%v,%v", i, line, got, ok, wantTime, wantOK)
+		}
+	}
+	if want := (timeHint{pos: 0, layout: 0, ok: true}); hint != want {
+		t.Errorf("got hint %+v, want %+v", hint, want)
+	}
+}
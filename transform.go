@@ -1,7 +1,9 @@
 package main
 
-func transform(line string) string {
-	return string(eliminateOverStrike([]byte(line)))
+// Transforms a line (without its trailing newline) into the text that is
+// displayed and searched.
+func transform(line string, subs substitutions) string {
+	return subs.apply(string(eliminateOverStrike([]byte(line))))
 }
 
 func eliminateOverStrike(in []byte) []byte {
@@ -19,3 +19,7 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -2,69 +2,35 @@ package main
 
 import (
 	"fmt"
-	"regexp"
+	"math"
 	"runtime"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-func CreateView(m *Model) ScreenState {
+func CreateView(m *Model, buffer, buffers int) ScreenState {
 	state := NewScreenState(m.rows, m.cols)
 	state.Init()
 
 	regexes := m.regexes
 	if m.tmpRegex != nil {
-		regexes = append(regexes, regex{MixStyle(Invert, Invert), m.tmpRegex})
+		regexes = append(regexes, regex{Invert, m.tmpRegex})
 	}
 	if m.cmd.Mode == SearchCommand {
-		if re, err := regexp.Compile(m.cmd.Text); err == nil {
-			regexes = append(regexes, regex{MixStyle(Invert, Invert), re})
+		if re, err := compileSearch(m.cmd.Text, m.searchModes); err == nil {
+			regexes = append(regexes, regex{Invert, re})
 		}
 	}
 
 	assert(len(m.fwd) == 0 || m.fwd[0].offset == m.offset)
-	var lineBuf []byte
-	var styleBuf []Style
-	var fwdIdx int
-	lineRows := m.rows - 2 // 2 rows reserved for status line and command line.
-	for row := 0; row < lineRows; row++ {
-		if fwdIdx < len(m.fwd) {
-			usePrefix := len(lineBuf) != 0
-			if len(lineBuf) == 0 {
-				assert(len(styleBuf) == 0)
-				data := m.fwd[fwdIdx].data
-				if data[len(data)-1] == '\n' {
-					data = data[:len(data)-1]
-				}
-				data = transform(data)
-				lineBuf = renderLine(data)
-				styleBuf = renderStyle(data, regexes)
-				fwdIdx++
-			}
-			if !m.lineWrapMode {
-				if m.xPosition < len(lineBuf) {
-					copy(state.Chars[row*m.cols:(row+1)*m.cols], lineBuf[m.xPosition:])
-					copy(state.Styles[row*m.cols:(row+1)*m.cols], styleBuf[m.xPosition:])
-				}
-				lineBuf = nil
-				styleBuf = nil
-			} else {
-				var prefix string
-				if usePrefix && len(m.config.WrapPrefix)+1 < m.cols {
-					prefix = m.config.WrapPrefix
-				}
-				copy(state.Chars[row*m.cols:(row+1)*m.cols], prefix)
-				copiedA := copy(state.Chars[row*m.cols+len(prefix):(row+1)*m.cols], lineBuf)
-				copiedB := copy(state.Styles[row*m.cols+len(prefix):(row+1)*m.cols], styleBuf)
-				assert(copiedA == copiedB)
-				lineBuf = lineBuf[copiedA:]
-				styleBuf = styleBuf[copiedB:]
-			}
-		} else {
-			state.Chars[state.RowColIdx(row, 0)] = '~'
-		}
+	if m.columnMode {
+		drawColumns(m, state, regexes)
+	} else {
+		drawLines(m, state, regexes)
 	}
 
-	drawStatusLine(m, state)
+	drawStatusLine(m, state, buffer, buffers)
 
 	state.ColPos = m.cols - 1
 	commandLineText := ""
@@ -72,7 +38,10 @@ func CreateView(m *Model) ScreenState {
 		commandLineText = prompt(m.cmd.Mode) + m.cmd.Text
 		state.ColPos = min(state.ColPos, len(prompt(m.cmd.Mode))+m.cmd.Pos)
 	} else if m.longFileOpInProgress {
-		commandLineText = "Long operation in progress (interrupt to cancel)"
+		commandLineText = m.longFileOpDesc
+		if commandLineText == "" {
+			commandLineText = m.searchProgress.describe(m.fileSize)
+		}
 	} else {
 		if time.Now().Sub(m.msgSetAt) < msgLingerDuration {
 			commandLineText = m.msg
@@ -80,9 +49,9 @@ func CreateView(m *Model) ScreenState {
 	}
 
 	commandRow := m.rows - 1
-	copy(state.Chars[commandRow*m.cols:(commandRow+1)*m.cols], commandLineText)
+	copyString(state.Chars[commandRow*m.cols:(commandRow+1)*m.cols], commandLineText)
 	if m.cmd.Mode == SearchCommand {
-		if _, err := regexp.Compile(m.cmd.Text); err != nil {
+		if _, err := compileSearch(m.cmd.Text, m.searchModes); err != nil {
 			start := len(prompt(m.cmd.Mode))
 			end := start + len(m.cmd.Text)
 			for i := start; i < end; i++ {
@@ -94,6 +63,12 @@ func CreateView(m *Model) ScreenState {
 	if m.cmd.Mode == ColourCommand {
 		overlaySwatch(state)
 	}
+	if m.cmd.Mode == MarkCommand || m.cmd.Mode == GotoMarkCommand {
+		overlayMarks(m, state)
+	}
+	if m.timeline != nil {
+		overlayTimeline(m, state)
+	}
 	if m.debug {
 		overlayDebug(m, state)
 	}
@@ -104,16 +79,197 @@ func CreateView(m *Model) ScreenState {
 	return state
 }
 
-func renderLine(data string) []byte {
-	buf := make([]byte, len(data))
-	for i := range data {
-		buf[i] = displayByte(data[i])
+// Draws the lines on screen, wrapping them in line wrap mode.
+func drawLines(m *Model, state ScreenState, regexes []regex) {
+	gutter := m.gutterWidth()
+	textCols := m.cols - gutter
+	var lineBuf []rune
+	var styleBuf []Style
+	var fwdIdx int
+	for row := 0; row < m.lineRows(); row++ {
+		rowStart := row*m.cols + gutter
+		rowEnd := (row + 1) * m.cols
+		if fwdIdx < len(m.fwd) || len(lineBuf) != 0 {
+			usePrefix := len(lineBuf) != 0
+			if len(lineBuf) == 0 {
+				assert(len(styleBuf) == 0)
+				data, base := displayText(m.fwd[fwdIdx].data, m.ansiColours, m.substitutions, m.jsonDisplay())
+				styleTag(m.content, data, base)
+				lineBuf, styleBuf = renderLine(data, renderStyle(data, base, regexes), m.config.TabWidth)
+				if gutter > 0 {
+					drawGutter(m, state, row, m.fwd[fwdIdx].offset)
+				}
+				fwdIdx++
+			}
+			if !m.lineWrapMode {
+				if m.xPosition < len(lineBuf) {
+					cells := lineBuf[m.xPosition:]
+					n := copy(state.Chars[rowStart:rowEnd], cells)
+					copy(state.Styles[rowStart:rowEnd], styleBuf[m.xPosition:])
+					// Blank out double width chars split by the screen edges.
+					if cells[0] == continuationCell {
+						state.Chars[rowStart] = ' '
+					}
+					if n < len(cells) && cells[n] == continuationCell {
+						state.Chars[rowStart+n-1] = ' '
+					}
+				}
+				lineBuf = nil
+				styleBuf = nil
+			} else {
+				var prefix string
+				if usePrefix && len(m.config.WrapPrefix)+1 < textCols {
+					prefix = m.config.WrapPrefix
+				}
+				copyString(state.Chars[rowStart:rowEnd], prefix)
+				n := fitCells(lineBuf, textCols-len(prefix))
+				cells := lineBuf[:n]
+				if n > 0 && cells[n-1] == rowBreakCell {
+					cells = cells[:n-1]
+				}
+				copy(state.Chars[rowStart+len(prefix):rowEnd], cells)
+				copy(state.Styles[rowStart+len(prefix):rowEnd], styleBuf[:len(cells)])
+				lineBuf = lineBuf[n:]
+				styleBuf = styleBuf[n:]
+			}
+		} else {
+			state.Chars[state.RowColIdx(row, 0)] = '~'
+		}
+	}
+}
+
+// Draws the lines on screen as aligned columns of their fields, with a row
+// of column headers at the top. The x position is the number of columns
+// scrolled past.
+func drawColumns(m *Model, state ScreenState, regexes []regex) {
+	layout, lines := m.screenColumns()
+	skip := clamp(m.xPosition, 0, max(0, len(layout.names)-1))
+	gutter := m.gutterWidth()
+	draw := func(row int, text string, base []Style) {
+		cells, styles := renderLine(text, base, m.config.TabWidth)
+		rowStart := row*m.cols + gutter
+		rowEnd := (row + 1) * m.cols
+		n := fitCells(cells, rowEnd-rowStart)
+		copy(state.Chars[rowStart:rowEnd], cells[:n])
+		copy(state.Styles[rowStart:rowEnd], styles[:n])
 	}
-	return buf
+
+	for col := 0; col < m.cols; col++ {
+		state.Styles[col] = Invert
+	}
+	header := layout.format(func(name string) string { return name }, skip)
+	headerStyles := make([]Style, len(header))
+	for i := range headerStyles {
+		headerStyles[i] = Invert
+	}
+	draw(0, header, headerStyles)
+
+	for row := 1; row <= m.lineRows(); row++ {
+		if row > len(lines) {
+			state.Chars[state.RowColIdx(row, 0)] = '~'
+			continue
+		}
+		if gutter > 0 {
+			drawGutter(m, state, row, m.fwd[row-1].offset)
+		}
+		text := layout.row(lines[row-1], skip)
+		draw(row, text, renderStyle(text, make([]Style, len(text)), regexes))
+	}
+}
+
+// Draws the mark name (if any) and line number (if known) for the line at an
+// offset in the gutter of a screen row.
+func drawGutter(m *Model, state ScreenState, row int, offset int) {
+	rowStart := row * m.cols
+	markCols := m.markGutterWidth()
+	if name := m.markAt(offset); name != 0 && markCols > 0 {
+		state.Chars[rowStart] = rune(name)
+		state.Styles[rowStart] = Invert
+	}
+	if !m.showLineNumbers {
+		return
+	}
+	if num, ok := m.lineNumber(offset); ok {
+		numCols := m.gutterWidth() - markCols
+		copyString(state.Chars[rowStart+markCols:rowStart+markCols+numCols], fmt.Sprintf("%*d ", numCols-1, num+1))
+	}
+}
+
+// Prepares a line for display, giving its text along with the base style of
+// each byte of the text. JSON log entries may be formatted, in which case
+// expanded entries contain newlines between rows.
+func displayText(data string, ansiColours bool, subs substitutions, json jsonDisplay) (string, []Style) {
+	if data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	data = transform(data, subs)
+	if json != jsonRaw {
+		if text, styles, ok := formatJSONLine(data, json == jsonExpanded); ok {
+			return text, styles
+		}
+	}
+	if ansiColours {
+		return parseSGR(data)
+	}
+	return data, make([]Style, len(data))
+}
+
+// Styles the tag at the start of a line, for content that has tags.
+func styleTag(content Content, data string, base []Style) {
+	tagger, ok := content.(Tagger)
+	if !ok {
+		return
+	}
+	n, style := tagger.TagStyle(data)
+	for i := 0; i < n; i++ {
+		base[i] = style
+	}
+}
+
+// Renders the text of a line into screen cells. The style of each byte of the
+// text is carried over to the cell(s) displaying it. Tabs are expanded to the
+// next tab stop.
+func renderLine(data string, styles []Style, tabWidth int) ([]rune, []Style) {
+	cells := make([]rune, 0, len(data))
+	cellStyles := make([]Style, 0, len(data))
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRuneInString(data[i:])
+		style := styles[i]
+		i += size
+		if r == '\n' {
+			cells = append(cells, rowBreakCell)
+			cellStyles = append(cellStyles, style)
+			continue
+		}
+		if r == '\t' {
+			for n := tabWidth - len(cells)%tabWidth; n > 0; n-- {
+				cells = append(cells, ' ')
+				cellStyles = append(cellStyles, style)
+			}
+			continue
+		}
+		if r == utf8.RuneError && size == 1 {
+			r = invalidPlaceholder
+		} else {
+			r = displayRune(r)
+		}
+		switch runeWidth(r) {
+		case 0:
+			// Combining marks would need to share a cell with the
+			// preceding char, which isn't supported.
+		case 1:
+			cells = append(cells, r)
+			cellStyles = append(cellStyles, style)
+		case 2:
+			cells = append(cells, r, continuationCell)
+			cellStyles = append(cellStyles, style, style)
+		}
+	}
+	return cells, cellStyles
 }
 
-func renderStyle(data string, regexes []regex) []Style {
-	buf := make([]Style, len(data))
+func renderStyle(data string, base []Style, regexes []regex) []Style {
+	buf := base
 	for _, regex := range regexes {
 		for _, match := range regex.re.FindAllStringIndex(data, -1) {
 			for i := match[0]; i < match[1]; i++ {
@@ -124,10 +280,10 @@ func renderStyle(data string, regexes []regex) []Style {
 	return buf
 }
 
-func drawStatusLine(m *Model, state ScreenState) {
+func drawStatusLine(m *Model, state ScreenState, buffer, buffers int) {
 	statusRow := m.rows - 2
 	for col := 0; col < state.Cols; col++ {
-		state.Styles[statusRow*m.cols+col] = MixStyle(Invert, Invert)
+		state.Styles[statusRow*m.cols+col] = Invert
 	}
 
 	// Offset percentage.
@@ -142,6 +298,18 @@ func drawStatusLine(m *Model, state ScreenState) {
 		pctStr = fmt.Sprintf("%3.1f%%", pct)
 	}
 
+	// Line number.
+	var lineNumStr string
+	lines, indexed := m.index.Lines()
+	if num, ok := m.lineNumber(m.offset); ok {
+		lineNumStr = fmt.Sprintf("ln:%d/%d ", num+1, lines)
+		if indexed < m.fileSize {
+			lineNumStr = fmt.Sprintf("ln:%d/%d+ ", num+1, lines)
+		}
+	} else if m.fileSize > 0 {
+		lineNumStr = fmt.Sprintf("ln:? (indexing %d%%) ", indexed*100/m.fileSize)
+	}
+
 	// Line wrap mode.
 	var lineWrapMode string
 	if m.lineWrapMode {
@@ -150,21 +318,60 @@ func drawStatusLine(m *Model, state ScreenState) {
 		lineWrapMode = "line-wrap-mode:off"
 	}
 
-	reStyle := MixStyle(Invert, Invert)
+	reStyle := Invert
 	reLabel := "re"
 	reStr := "<none>"
 	if m.tmpRegex != nil {
 		reLabel = "re(tmp)"
 		reStr = m.tmpRegex.String()
-		reStyle = MixStyle(Invert, Invert)
+		reStyle = Invert
 	} else if len(m.regexes) > 0 {
 		reLabel = fmt.Sprintf("re(%d)", len(m.regexes))
 		reStr = m.regexes[0].re.String()
 		reStyle = m.regexes[0].style
 	}
+	if modes := m.searchModes.String(); modes != "" {
+		reLabel += "[" + modes + "]"
+	}
 
-	statusRight := lineWrapMode + " " + pctStr + " "
-	statusLeft := " " + m.filename + " " + reLabel + ":" + reStr
+	var following string
+	if m.following {
+		following = "following "
+	}
+
+	var filterStr string
+	if len(m.filters) > 0 {
+		filterStr = fmt.Sprintf("filters:%d ", len(m.filters))
+		if !m.filtersEnabled {
+			filterStr = fmt.Sprintf("filters:%d(off) ", len(m.filters))
+		}
+	}
+
+	var subsStr string
+	if len(m.substitutions) > 0 {
+		subsStr = fmt.Sprintf("subs:%d ", len(m.substitutions))
+	}
+
+	var displayStr string
+	if m.prettyJSON {
+		displayStr = "json "
+	}
+	if m.columnMode {
+		displayStr = "columns "
+	}
+
+	var matchStr string
+	if m.matchCount != nil {
+		matchStr = m.matchCount.describe(m.offset) + " "
+	}
+
+	statusRight := matchStr + following + filterStr + subsStr + displayStr + lineNumStr + lineWrapMode + " " + pctStr + " "
+	var bufferLabel string
+	if buffers > 1 {
+		bufferLabel = fmt.Sprintf("[%d/%d] ", buffer+1, buffers)
+	}
+
+	statusLeft := " " + bufferLabel + m.filename + " " + reLabel + ":" + reStr
 
 	for i := 0; i < len(reStr); i++ {
 		offset := len(statusLeft) - len(reStr)
@@ -172,68 +379,131 @@ func drawStatusLine(m *Model, state ScreenState) {
 	}
 
 	buf := state.Chars[statusRow*m.cols : (statusRow+1)*m.cols]
-	copy(buf[max(0, len(buf)-len(statusRight)):], statusRight)
-	copy(buf[:], statusLeft)
+	copyString(buf[max(0, len(buf)-len(statusRight)):], statusRight)
+	copyString(buf[:], statusLeft)
 }
 
 func overlaySwatch(state ScreenState) {
 	const sideBorder = 2
 	const topBorder = 1
 	const colourWidth = 4
-	const swatchWidth = len(styles)*colourWidth + sideBorder*2
-	const swatchHeight = len(styles) + topBorder*2
+	const innerWidth = len(colours) * colourWidth
+	const swatchWidth = innerWidth + sideBorder*2
+
+	// The 6x6x6 colour cube (16-231), followed by the greys (232-255).
+	const cubeRows = 6
+	const cubeCols = 36
+	attrLine := "bold italic underline reverse"
+	helpLines := []string{
+		"e.g. 21, fg:196 bg:#303030 bold",
+		"cube 16+36*row+col, greys 232+",
+	}
+	swatchHeight := len(colours) + 1 + 1 + cubeRows + 1 + len(helpLines) + topBorder*2
 
 	startCol := (state.Cols - swatchWidth) / 2
-	startRow := (state.Rows() - swatchHeight) / 2
+	startRow := (state.Rows() - 2 - swatchHeight) / 2
 	endCol := startCol + swatchWidth
 	endRow := startRow + swatchHeight
 
+	// Writes a cell, ignoring any that fall outside of the screen.
+	set := func(row, col int, ch rune, style Style) {
+		if row < 0 || row >= state.Rows() || col < 0 || col >= state.Cols {
+			return
+		}
+		idx := state.RowColIdx(row, col)
+		state.Chars[idx] = ch
+		state.Styles[idx] = style
+	}
+
 	for row := startRow; row < endRow; row++ {
 		for col := startCol; col < endCol; col++ {
-			idx := state.RowColIdx(row, col)
+			var style Style
 			if col-startCol < 2 || endCol-col <= 2 || row-startRow < 1 || endRow-row <= 1 {
-				state.Styles[idx] = MixStyle(Invert, Invert)
+				style = Invert
+			}
+			set(row, col, ' ', style)
+		}
+	}
+
+	row := startRow + topBorder
+	innerStart := startCol + sideBorder
+	for fg := 0; fg < len(colours); fg++ {
+		for bg := 0; bg < len(colours); bg++ {
+			start := innerStart + bg*colourWidth
+			style := MixStyle(colours[fg], colours[bg])
+			for i := 0; i < colourWidth; i++ {
+				set(row, start+i, ' ', style)
+			}
+			set(row, start+1, rune(fg)+'0', style)
+			set(row, start+2, rune(bg)+'0', style)
+		}
+		row++
+	}
+	row++
+
+	var col int
+	for _, word := range strings.Fields(attrLine) {
+		for _, ac := range attrCodes {
+			if ac.name == word {
+				for _, ch := range word {
+					set(row, innerStart+col, ch, Style{}.with(ac.attr))
+					col++
+				}
 			}
-			state.Chars[idx] = ' '
 		}
+		col++
 	}
+	row++
 
-	for fg := 0; fg < len(styles); fg++ {
-		for bg := 0; bg < len(styles); bg++ {
-			start := startCol + sideBorder + bg*colourWidth
-			row := startRow + topBorder + fg
-			state.Chars[state.RowColIdx(row, start+1)] = byte(fg) + '0'
-			state.Chars[state.RowColIdx(row, start+2)] = byte(bg) + '0'
-			style := MixStyle(styles[fg], styles[bg])
-			for i := 0; i < 4; i++ {
-				state.Styles[state.RowColIdx(row, start+i)] = style
+	for r := 0; r < cubeRows+1; r++ {
+		for c := 0; c < cubeCols; c++ {
+			idx := 16 + r*cubeCols + c
+			if idx > 255 {
+				break
 			}
+			set(row, innerStart+c, ' ', MixStyle(Default, IndexedColour(uint8(idx))))
 		}
+		row++
+	}
+
+	for _, line := range helpLines {
+		for i, ch := range line {
+			set(row, innerStart+i, ch, Style{})
+		}
+		row++
 	}
 }
 
-func displayByte(b byte) byte {
-	assert(b != '\n')
+func displayRune(r rune) rune {
+	assert(r != '\n')
 	switch {
-	case b >= 32 && b < 126:
-		return b
-	case b == '\t':
-		return ' '
-	default:
+	case r < 32 || r == 127 || (r >= 0x80 && r < 0xa0):
 		return '?'
+	default:
+		return r
 	}
 }
 
 func prompt(cmd CommandMode) string {
 	switch cmd {
 	case SearchCommand:
-		return "Enter search regexp (interrupt to cancel): "
+		return "Enter search regexp (^T smart-case, ^R literal, ^W word, interrupt to cancel): "
 	case ColourCommand:
-		return "Enter colour code (interrupt to cancel): "
+		return "Enter colour code or style (interrupt to cancel): "
 	case SeekCommand:
 		return "Enter seek percentage (interrupt to cancel): "
 	case BisectCommand:
 		return "Enter bisect target (interrupt to cancel): "
+	case TimeCommand:
+		return "Enter time, e.g. 14:32, 2024-05-01 14:32 or -15m (interrupt to cancel): "
+	case ExCommand:
+		return ":"
+	case FilterCommand:
+		return "Enter filter regexp, prefix with ! to hide matches (interrupt to cancel): "
+	case MarkCommand:
+		return "Enter mark name to set (interrupt to cancel): "
+	case GotoMarkCommand:
+		return "Enter mark name to jump to (interrupt to cancel): "
 	case QuitCommand:
 		return "Do you really want to quit? (y/n): "
 	}
@@ -268,13 +538,13 @@ func overlayDebug(m *Model, state ScreenState) {
 	for row := startRow; row < endRow; row++ {
 		for col := startCol; col < endCol; col++ {
 			idx := state.RowColIdx(row, col)
-			state.Styles[idx] = MixStyle(Invert, Invert)
+			state.Styles[idx] = Invert
 			state.Chars[idx] = ' '
 		}
 	}
 
 	for i, line := range lines {
-		copy(state.Chars[state.RowColIdx(i+startRow, startCol+1):], line)
+		copyString(state.Chars[state.RowColIdx(i+startRow, startCol+1):], line)
 	}
 }
 
@@ -300,11 +570,33 @@ func overlayHelp(m *Model, state ScreenState) {
 		}
 	}
 
-	lines := []string{
+	header := []string{
 		"CONTROLS: (press '?' to exit)", "",
 	}
+	var entries []string
 	for _, ctrl := range ctrls {
-		lines = append(lines, fmt.Sprintf("%*s - %s", longestKey, ctrl.keys, ctrl.desc))
+		entries = append(entries, fmt.Sprintf("%*s - %s", longestKey, ctrl.keys, ctrl.desc))
+	}
+	entries = append(entries, "")
+	entries = append(entries, jumpListLines(m)...)
+
+	// Flow the entries into multiple columns if they don't fit vertically.
+	availRows := max(1, state.Rows()-2-2-len(header))
+	numCols := (len(entries) + availRows - 1) / availRows
+	colRows := (len(entries) + numCols - 1) / numCols
+	var colWidth int
+	for _, entry := range entries {
+		colWidth = max(colWidth, len(entry))
+	}
+	lines := header
+	for row := 0; row < colRows; row++ {
+		var line string
+		for col := 0; col < numCols; col++ {
+			if i := col*colRows + row; i < len(entries) {
+				line += fmt.Sprintf("%-*s", colWidth+2, entries[i])
+			}
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
 	}
 
 	var longestLength int
@@ -312,21 +604,189 @@ func overlayHelp(m *Model, state ScreenState) {
 		longestLength = max(longestLength, len(line))
 	}
 
-	startCol := (state.Cols-longestLength)/2 - 1
-	startRow := (state.Rows() - 2 - len(lines)) / 2
-	endCol := startCol + longestLength + 2
-	endRow := startRow + len(lines)
+	startCol := max(0, (state.Cols-longestLength)/2-1)
+	startRow := max(0, (state.Rows()-2-len(lines))/2)
+	endCol := min(state.Cols, startCol+longestLength+2)
+	endRow := min(state.Rows()-2, startRow+len(lines))
 
 	for row := startRow; row < endRow; row++ {
 		for col := startCol; col < endCol; col++ {
 			idx := state.RowColIdx(row, col)
-			state.Styles[idx] = MixStyle(Invert, Invert)
+			state.Styles[idx] = Invert
 			state.Chars[idx] = ' '
 		}
 	}
 
 	for i, line := range lines {
-		copy(state.Chars[state.RowColIdx(i+startRow, startCol+1):], line)
+		if row := i + startRow; row < endRow && startCol+1 < endCol {
+			lineStart := state.RowColIdx(row, startCol+1)
+			copyString(state.Chars[lineStart:lineStart+endCol-startCol-1], line)
+		}
+	}
+}
+
+// Lists the marks that have been set, along with a preview of each marked
+// line.
+func overlayMarks(m *Model, state ScreenState) {
+	const maxWidth = 100
+	width := min(maxWidth, state.Cols-4)
+	if width < 10 {
+		return
 	}
 
+	type entry struct {
+		label   string
+		preview string
+	}
+	entries := []entry{{label: "MARKS:"}}
+	for _, name := range m.markNames() {
+		mk := m.marks[name]
+		label := fmt.Sprintf("%c  offset %d", name, mk.offset)
+		if num, ok := m.lineNumber(mk.offset); ok {
+			label = fmt.Sprintf("%c  line %d", name, num+1)
+		}
+		entries = append(entries, entry{label, mk.preview})
+	}
+	if len(entries) == 1 {
+		entries = append(entries, entry{label: "(no marks set)"})
+	}
+	var labelWidth int
+	for _, e := range entries[1:] {
+		labelWidth = max(labelWidth, len(e.label))
+	}
+
+	startCol := (state.Cols - width) / 2
+	startRow := max(0, (state.Rows()-2-len(entries)-2)/2)
+	endRow := min(state.Rows()-2, startRow+len(entries)+2)
+	for row := startRow; row < endRow; row++ {
+		for col := startCol; col < startCol+width; col++ {
+			idx := state.RowColIdx(row, col)
+			state.Styles[idx] = Invert
+			state.Chars[idx] = ' '
+		}
+	}
+
+	for i, e := range entries {
+		row := startRow + 1 + i
+		if row >= endRow-1 {
+			break
+		}
+		cells := state.Chars[state.RowColIdx(row, startCol+2):state.RowColIdx(row, startCol+width-2)]
+		copyString(cells, e.label)
+		if e.preview == "" || labelWidth+2 >= len(cells) {
+			continue
+		}
+		cells = cells[labelWidth+2:]
+		json := m.jsonDisplay()
+		if json == jsonExpanded {
+			json = jsonCompact // Previews are a single row.
+		}
+		text, base := displayText(e.preview, m.ansiColours, m.substitutions, json)
+		preview, _ := renderLine(text, base, m.config.TabWidth)
+		n := fitCells(preview, len(cells))
+		copy(cells, preview[:n])
+	}
+}
+
+// Draws the timeline as a histogram across the screen, with the estimated
+// number of lines in each bucket as a bar. The part of each bar made up of
+// regex matches is drawn in red.
+func overlayTimeline(m *Model, state ScreenState) {
+	t := m.timeline
+	n := timelineBuckets(state.Cols)
+	height := state.Rows() - 2 - 5 // Title, axis, marker, info and keys rows.
+	if n < 10 || height < 2 {
+		return
+	}
+	startCol := (state.Cols - n) / 2
+	for row := 0; row < state.Rows()-2; row++ {
+		for col := 0; col < state.Cols; col++ {
+			idx := state.RowColIdx(row, col)
+			state.Styles[idx] = Invert
+			state.Chars[idx] = ' '
+		}
+	}
+	text := func(row int, str string) {
+		copyString(state.Chars[state.RowColIdx(row, startCol):state.RowColIdx(row, startCol+n)], str)
+	}
+
+	axis := "position in file"
+	if t.byTime {
+		axis = "time"
+	}
+	title := "TIMELINE: log volume against " + axis
+	if t.re != nil {
+		title += fmt.Sprintf(" (matches of /%s/ in red)", t.re)
+	}
+	text(0, title)
+
+	buckets := t.buckets(n)
+	var most float64
+	for _, b := range buckets {
+		most = math.Max(most, b.lines)
+	}
+	selected := clamp(t.selected, 0, n-1)
+	eighths := []rune("▁▂▃▄▅▆▇█")
+	for i, b := range buckets {
+		if most == 0 {
+			break
+		}
+		col := startCol + i
+		bar := int(math.Ceil(b.lines / most * float64(height*8)))
+		hits := int(math.Ceil(b.hits / most * float64(height*8)))
+		style := Style{}
+		if i == selected {
+			style = MixStyle(Cyan, Default)
+		}
+		for h := 0; h < height; h++ {
+			idx := state.RowColIdx(height-h, col)
+			state.Styles[idx] = Style{}
+			fill := min(8, bar-h*8)
+			if fill <= 0 {
+				continue
+			}
+			state.Chars[idx] = eighths[fill-1]
+			state.Styles[idx] = style
+			if hits-h*8 > fill/2 {
+				state.Styles[idx] = MixStyle(Red, Default)
+			}
+		}
+	}
+
+	left, right := "0%", "100%"
+	if t.byTime {
+		lo, hi := t.timeRange()
+		left, right = lo.Format(timelineTimeFormat), hi.Format(timelineTimeFormat)
+	}
+	axisRow := height + 1
+	text(axisRow, left)
+	if len(left)+len(right)+1 < n {
+		copyString(state.Chars[state.RowColIdx(axisRow, startCol+n-len(right)):state.RowColIdx(axisRow, startCol+n)], right)
+	}
+	state.Chars[state.RowColIdx(axisRow+1, startCol+selected)] = '^'
+	text(axisRow+2, t.describeBucket(buckets[selected], selected, n))
+	text(axisRow+3, "h/l select, H/L first/last, enter jump, a switch axis, T close")
+}
+
+const jumpListSize = 10
+
+// Describes the most recent entries of the jump list, with the current
+// position marked.
+func jumpListLines(m *Model) []string {
+	lines := []string{"JUMP LIST: (newest last)"}
+	if len(m.jumps) == 0 {
+		return append(lines, "  (empty)")
+	}
+	for i := max(0, len(m.jumps)-jumpListSize); i < len(m.jumps); i++ {
+		marker := "  "
+		if i == m.jumpIdx {
+			marker = "> "
+		}
+		line := fmt.Sprintf("%soffset %d", marker, m.jumps[i])
+		if num, ok := m.lineNumber(m.jumps[i]); ok {
+			line += fmt.Sprintf(" (line %d)", num+1)
+		}
+		lines = append(lines, line)
+	}
+	return lines
 }
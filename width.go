@@ -0,0 +1,89 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Placed in the cell following a double width character (which occupies
+// both cells).
+const continuationCell rune = -1
+
+// Ends a row early, for text that's displayed over several rows (e.g. an
+// expanded JSON log entry). It's only used in line wrap mode.
+const rowBreakCell rune = -2
+
+// Displayed in place of bytes that aren't valid UTF-8.
+const invalidPlaceholder rune = utf8.RuneError
+
+// Ranges of East Asian wide and fullwidth characters (and emoji), which
+// occupy two screen cells.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x1f300, 0x1f64f},
+	{0x1f900, 0x1f9ff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// Gives the number of screen cells that a (displayable) rune occupies.
+func runeWidth(r rune) int {
+	if r < 0x300 {
+		return 1
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, rng := range wideRanges {
+		if r < rng.lo {
+			break
+		}
+		if r <= rng.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Gives the number of cells (up to width) that can be displayed on a single
+// row without splitting a double width character.
+func fitCells(cells []rune, width int) int {
+	for i := 0; i < len(cells) && i <= width; i++ {
+		if cells[i] == rowBreakCell {
+			return i + 1 // The break is consumed along with the row.
+		}
+	}
+	if width >= len(cells) {
+		return len(cells)
+	}
+	if width > 1 && cells[width] == continuationCell {
+		return width - 1
+	}
+	return width
+}
+
+// Gives the number of rows needed to display cells when wrapped. The first
+// row has firstWidth cells available, and the rest have restWidth.
+func wrapRows(cells []rune, firstWidth, restWidth int) int {
+	rows := 1
+	cells = cells[fitCells(cells, firstWidth):]
+	for len(cells) > 0 {
+		cells = cells[fitCells(cells, restWidth):]
+		rows++
+	}
+	return rows
+}
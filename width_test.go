@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderLine(t *testing.T) {
+	const c = continuationCell
+	for i, test := range []struct {
+		in    string
+		cells []rune
+	}{
+		{"", []rune{}},
+		{"a~", []rune{'a', '~'}},
+		{"a\x01", []rune{'a', '?'}},
+		{"héllo", []rune{'h', 'é', 'l', 'l', 'o'}},
+		{"e\u0301", []rune{'e'}},
+		{"日本", []rune{'日', c, '本', c}},
+		{"a\xffb", []rune{'a', invalidPlaceholder, 'b'}},
+		{"\ta", []rune{' ', ' ', ' ', ' ', 'a'}},
+		{"ab\tc", []rune{'a', 'b', ' ', ' ', 'c'}},
+		{"日\tc", []rune{'日', c, ' ', ' ', 'c'}},
+		{"abcd\te", []rune{'a', 'b', 'c', 'd', ' ', ' ', ' ', ' ', 'e'}},
+	} {
+		cells, styles := renderLine(test.in, make([]Style, len(test.in)), 4)
+		if !reflect.DeepEqual(cells, test.cells) {
+			t.Errorf("%d: in=%q got=%q want=%q", i, test.in, cells, test.cells)
+		}
+		if len(styles) != len(cells) {
+			t.Errorf("%d: styles=%d cells=%d", i, len(styles), len(cells))
+		}
+	}
+}
+
+func TestWrapRows(t *testing.T) {
+	cells, _ := renderLine("ab日本", make([]Style, 8), 8)
+	for i, test := range []struct {
+		first, rest, want int
+	}{
+		{6, 6, 1},
+		{5, 5, 2},
+		{3, 2, 3},
+		{2, 2, 3},
+	} {
+		if got := wrapRows(cells, test.first, test.rest); got != test.want {
+			t.Errorf("%d: got=%d want=%d", i, got, test.want)
+		}
+	}
+}
+
+func TestRenderLineTabStyle(t *testing.T) {
+	red := MixStyle(Red, Default)
+	_, styles := renderLine("a\tb", []Style{{}, red, {}}, 4)
+	want := []Style{{}, red, red, red, {}}
+	if !reflect.DeepEqual(styles, want) {
+		t.Errorf("got=%v want=%v", styles, want)
+	}
+}